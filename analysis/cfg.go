@@ -0,0 +1,242 @@
+// Package analysis statically separates code from data in CHIP-8 ROMs
+// and builds their control-flow graph.
+package analysis
+
+import (
+	"go-r8t/cpu"
+	"sort"
+)
+
+// EdgeKind describes how control passes from one block to another.
+type EdgeKind string
+
+const (
+	EdgeFallthrough EdgeKind = "fallthrough" // The next instruction in memory
+	EdgeJump        EdgeKind = "jump"        // 1NNN
+	EdgeCall        EdgeKind = "call"        // 2NNN, to the subroutine
+	EdgeReturn      EdgeKind = "return"      // 2NNN, to the instruction after the call once the subroutine returns
+	EdgeSkip        EdgeKind = "skip"        // A skip instruction whose condition is true
+)
+
+// Instruction is a decoded instruction of the program.
+type Instruction struct {
+	Address  uint16 `json:"address"`
+	Opcode   uint16 `json:"opcode"`
+	Mnemonic string `json:"mnemonic"`
+}
+
+// Block is a basic block: a run of instructions that is only entered at
+// its first instruction and only left after its last.
+type Block struct {
+	Start        uint16        `json:"start"`
+	End          uint16        `json:"end"` // Address after the last instruction
+	Instructions []Instruction `json:"instructions"`
+	Indirect     bool          `json:"indirect,omitempty"` // Ends in a BNNN jump whose target is unknown
+	Exit         bool          `json:"exit,omitempty"`     // Ends in a RET or EXIT, or runs off the end of the ROM
+}
+
+// Edge is a control-flow edge between two blocks.
+type Edge struct {
+	From uint16   `json:"from"`
+	To   uint16   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// Range is a half-open address range [Start, End).
+type Range struct {
+	Start uint16 `json:"start"`
+	End   uint16 `json:"end"`
+}
+
+// CFG is the control-flow graph of a program, together with the code/data
+// separation it implies.
+type CFG struct {
+	Entry         uint16   `json:"entry"`
+	Blocks        []*Block `json:"blocks"` // Sorted by start address
+	Edges         []Edge   `json:"edges"`
+	Subroutines   []uint16 `json:"subroutines"`    // Targets of 2NNN
+	IndirectJumps []uint16 `json:"indirect_jumps"` // Addresses of BNNN instructions
+	Code          []Range  `json:"code"`           // ROM bytes reached as instructions
+	Data          []Range  `json:"data"`           // ROM bytes never reached as instructions
+}
+
+// successor is a control-flow target of a single instruction
+type successor struct {
+	address uint16
+	kind    EdgeKind
+}
+
+// flow classifies the control flow of the instruction at address.
+// It returns the instruction's successors and whether it ends a basic block.
+func flow(address, opcode uint16) (next []successor, ends bool) {
+	nnn := opcode & 0x0FFF
+	step := successor{address + 2, EdgeFallthrough}
+
+	switch {
+	case opcode == 0x00EE, opcode == 0x00FD: // RET, EXIT
+		return nil, true
+	case opcode&0xF000 == 0x1000: // JP NNN
+		return []successor{{nnn, EdgeJump}}, true
+	case opcode&0xF000 == 0x2000: // CALL NNN
+		return []successor{{nnn, EdgeCall}, {address + 2, EdgeReturn}}, true
+	case opcode&0xF000 == 0xB000: // JP V0, NNN
+		return nil, true
+	case isSkip(opcode):
+		return []successor{step, {address + 4, EdgeSkip}}, true
+	}
+	return []successor{step}, false
+}
+
+// isSkip reports whether an instruction conditionally skips the next one
+func isSkip(opcode uint16) bool {
+	switch opcode & 0xF000 {
+	case 0x3000, 0x4000:
+		return true
+	case 0x5000, 0x9000:
+		return opcode&0x000F == 0
+	case 0xE000:
+		return opcode&0x00FF == 0x9E || opcode&0x00FF == 0xA1
+	}
+	return false
+}
+
+// Build analyses a ROM loaded at the platform's load address.
+// Code is found by recursive descent from the entry point, following jumps,
+// calls and both outcomes of skips. BNNN jumps are flagged as indirect and
+// not followed, so code that is only reached through them shows up as data.
+// Parameters:
+//   - rom: The program bytes
+//   - platform: The platform the ROM runs on, which decides where it is loaded and starts
+func Build(rom []byte, platform cpu.Platform) *CFG {
+	d := platform.Descriptor()
+	start, entry := d.LoadAddress, d.Entry(rom)
+	end := start + uint16(len(rom))
+	inROM := func(address uint16) bool {
+		return address >= start && address+1 < end
+	}
+	opcodeAt := func(address uint16) uint16 {
+		offset := address - start
+		return uint16(rom[offset])<<8 | uint16(rom[offset+1])
+	}
+
+	g := &CFG{Entry: entry}
+
+	// Find every reachable instruction and the block leaders
+	visited := make(map[uint16]bool)
+	leaders := map[uint16]bool{entry: true}
+	subroutines := make(map[uint16]bool)
+	work := []uint16{entry}
+	for len(work) > 0 {
+		address := work[len(work)-1]
+		work = work[:len(work)-1]
+		if visited[address] || !inROM(address) {
+			continue
+		}
+		visited[address] = true
+
+		opcode := opcodeAt(address)
+		next, ends := flow(address, opcode)
+		for _, s := range next {
+			if ends {
+				leaders[s.address] = true
+			}
+			if s.kind == EdgeCall {
+				subroutines[s.address] = true
+			}
+			work = append(work, s.address)
+		}
+		if opcode&0xF000 == 0xB000 {
+			g.IndirectJumps = append(g.IndirectJumps, address)
+		}
+	}
+
+	// Split the reachable instructions into blocks
+	var starts []uint16
+	for address := range leaders {
+		if visited[address] {
+			starts = append(starts, address)
+		}
+	}
+	sortAddresses(starts)
+	for _, address := range starts {
+		block := &Block{Start: address}
+		for {
+			opcode := opcodeAt(address)
+			block.Instructions = append(block.Instructions, Instruction{
+				Address:  address,
+				Opcode:   opcode,
+				Mnemonic: cpu.Disassemble(opcode),
+			})
+			next, ends := flow(address, opcode)
+			address += 2
+			if ends {
+				block.Indirect = opcode&0xF000 == 0xB000
+				block.Exit = len(next) == 0 && !block.Indirect
+				for _, s := range next {
+					g.addEdge(block.Start, s, visited)
+				}
+				break
+			}
+			if !visited[address] {
+				// Ran off the end of the ROM
+				block.Exit = true
+				break
+			}
+			if leaders[address] {
+				g.addEdge(block.Start, next[0], visited)
+				break
+			}
+		}
+		block.End = address
+		g.Blocks = append(g.Blocks, block)
+	}
+
+	for address := range subroutines {
+		if visited[address] {
+			g.Subroutines = append(g.Subroutines, address)
+		}
+	}
+	sortAddresses(g.Subroutines)
+	sortAddresses(g.IndirectJumps)
+
+	// Bytes covered by a reachable instruction are code, everything else is data
+	code := make([]bool, len(rom))
+	for address := range visited {
+		code[address-start] = true
+		code[address-start+1] = true
+	}
+	for offset := 0; offset < len(rom); {
+		run := offset
+		for run < len(rom) && code[run] == code[offset] {
+			run++
+		}
+		r := Range{start + uint16(offset), start + uint16(run)}
+		if code[offset] {
+			g.Code = append(g.Code, r)
+		} else {
+			g.Data = append(g.Data, r)
+		}
+		offset = run
+	}
+	return g
+}
+
+// addEdge adds an edge to a successor that is part of the program
+func (g *CFG) addEdge(from uint16, to successor, visited map[uint16]bool) {
+	if visited[to.address] {
+		g.Edges = append(g.Edges, Edge{From: from, To: to.address, Kind: to.kind})
+	}
+}
+
+// Block returns the block starting at address.
+func (g *CFG) Block(address uint16) (*Block, bool) {
+	i := sort.Search(len(g.Blocks), func(i int) bool { return g.Blocks[i].Start >= address })
+	if i < len(g.Blocks) && g.Blocks[i].Start == address {
+		return g.Blocks[i], true
+	}
+	return nil, false
+}
+
+func sortAddresses(addresses []uint16) {
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+}
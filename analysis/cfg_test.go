@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"go-r8t/cpu"
+	"reflect"
+	"testing"
+)
+
+// assemble converts opcodes into ROM bytes.
+func assemble(opcodes ...uint16) []byte {
+	rom := make([]byte, 0, 2*len(opcodes))
+	for _, opcode := range opcodes {
+		rom = append(rom, byte(opcode>>8), byte(opcode))
+	}
+	return rom
+}
+
+// starts returns the start addresses of the blocks of a graph.
+func starts(g *CFG) []uint16 {
+	var addresses []uint16
+	for _, block := range g.Blocks {
+		addresses = append(addresses, block.Start)
+	}
+	return addresses
+}
+
+func TestBuildSkips(t *testing.T) {
+	g := Build(assemble(
+		0x3000, // 200: SE V0, 0
+		0x6001, // 202: LD V0, 1
+		0x6102, // 204: LD V1, 2
+		0x1206, // 206: JP 206
+	), cpu.PlatformCHIP8)
+
+	if want := []uint16{0x200, 0x202, 0x204, 0x206}; !reflect.DeepEqual(starts(g), want) {
+		t.Errorf("blocks start at %03X, want %03X", starts(g), want)
+	}
+	wantEdges := []Edge{
+		{0x200, 0x202, EdgeFallthrough},
+		{0x200, 0x204, EdgeSkip},
+		{0x202, 0x204, EdgeFallthrough},
+		{0x204, 0x206, EdgeFallthrough},
+		{0x206, 0x206, EdgeJump},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("edges = %v, want %v", g.Edges, wantEdges)
+	}
+	if want := []Range{{0x200, 0x208}}; !reflect.DeepEqual(g.Code, want) || g.Data != nil {
+		t.Errorf("code = %v, data = %v; want %v and no data", g.Code, g.Data, want)
+	}
+}
+
+func TestBuildCallReturn(t *testing.T) {
+	g := Build(assemble(
+		0x2206, // 200: CALL 206
+		0x1202, // 202: JP 202
+		0x1234, // 204: data
+		0x00EE, // 206: RET
+	), cpu.PlatformCHIP8)
+
+	if want := []uint16{0x200, 0x202, 0x206}; !reflect.DeepEqual(starts(g), want) {
+		t.Errorf("blocks start at %03X, want %03X", starts(g), want)
+	}
+	wantEdges := []Edge{
+		{0x200, 0x206, EdgeCall},
+		{0x200, 0x202, EdgeReturn},
+		{0x202, 0x202, EdgeJump},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("edges = %v, want %v", g.Edges, wantEdges)
+	}
+	if want := []uint16{0x206}; !reflect.DeepEqual(g.Subroutines, want) {
+		t.Errorf("subroutines = %03X, want %03X", g.Subroutines, want)
+	}
+	if ret, ok := g.Block(0x206); !ok || !ret.Exit {
+		t.Errorf("RET block = %+v, %t; want an exit", ret, ok)
+	}
+	if want := []Range{{0x204, 0x206}}; !reflect.DeepEqual(g.Data, want) {
+		t.Errorf("data = %v, want %v", g.Data, want)
+	}
+	if !g.IsCode(0x206) || g.IsCode(0x204) {
+		t.Errorf("IsCode(206) = %t, IsCode(204) = %t; want true, false", g.IsCode(0x206), g.IsCode(0x204))
+	}
+}
+
+func TestBuildIndirectJump(t *testing.T) {
+	g := Build(assemble(
+		0x6002, // 200: LD V0, 2
+		0xB206, // 202: JP V0, 206
+		0x1204, // 204: JP 204
+		0x1208, // 206: JP 208, only reached through the indirect jump
+		0x00EE, // 208: RET
+	), cpu.PlatformCHIP8)
+
+	if len(g.Blocks) != 1 {
+		t.Fatalf("got %d blocks, want 1: %+v", len(g.Blocks), g.Blocks)
+	}
+	block := g.Blocks[0]
+	if !block.Indirect || block.Exit || block.End != 0x204 {
+		t.Errorf("block = %+v, want an indirect jump ending at 204 that isn't an exit", block)
+	}
+	if want := []uint16{0x202}; !reflect.DeepEqual(g.IndirectJumps, want) {
+		t.Errorf("indirect jumps = %03X, want %03X", g.IndirectJumps, want)
+	}
+	if g.Edges != nil {
+		t.Errorf("edges = %v, want none", g.Edges)
+	}
+	if want := []Range{{0x204, 0x20A}}; !reflect.DeepEqual(g.Data, want) {
+		t.Errorf("data = %v, want %v", g.Data, want)
+	}
+}
+
+func TestBuildOddLength(t *testing.T) {
+	rom := append(assemble(0x6001, 0x6102), 0xFF)
+	g := Build(rom, cpu.PlatformCHIP8)
+
+	if len(g.Blocks) != 1 {
+		t.Fatalf("got %d blocks, want 1: %+v", len(g.Blocks), g.Blocks)
+	}
+	if block := g.Blocks[0]; !block.Exit || block.End != 0x204 || len(block.Instructions) != 2 {
+		t.Errorf("block = %+v, want 2 instructions running off the end at 204", block)
+	}
+	if want := []Range{{0x200, 0x204}}; !reflect.DeepEqual(g.Code, want) {
+		t.Errorf("code = %v, want %v", g.Code, want)
+	}
+	if want := []Range{{0x204, 0x205}}; !reflect.DeepEqual(g.Data, want) {
+		t.Errorf("data = %v, want %v", g.Data, want)
+	}
+
+	// A jump to the last, incomplete instruction doesn't reach it
+	g = Build(append(assemble(0x1202), 0x00), cpu.PlatformCHIP8)
+	if g.Edges != nil || len(g.Blocks) != 1 {
+		t.Errorf("blocks = %+v, edges = %v; want one block and no edges", g.Blocks, g.Edges)
+	}
+}
+
+func TestBuildEntry(t *testing.T) {
+	// ETI-660 programs are loaded and start at 600
+	g := Build(assemble(0x6001, 0x1602), cpu.PlatformETI660)
+	if g.Entry != 0x600 || !reflect.DeepEqual(starts(g), []uint16{0x600, 0x602}) {
+		t.Errorf("entry = %03X, blocks start at %03X; want 600 and [600 602]", g.Entry, starts(g))
+	}
+	if want := []Range{{0x600, 0x604}}; !reflect.DeepEqual(g.Code, want) {
+		t.Errorf("code = %v, want %v", g.Code, want)
+	}
+
+	// Hires CHIP-8 programs start at 2C0, after the 1260 jump into the VIP's machine code
+	rom := make([]byte, 0xC4)
+	copy(rom, assemble(0x1260))
+	copy(rom[0xC0:], assemble(0x6001, 0x12C2))
+	g = Build(rom, cpu.PlatformCHIP8Hires)
+	if g.Entry != 0x2C0 || !reflect.DeepEqual(starts(g), []uint16{0x2C0, 0x2C2}) {
+		t.Errorf("entry = %03X, blocks start at %03X; want 2C0 and [2C0 2C2]", g.Entry, starts(g))
+	}
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"go-r8t/cpu"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Heatmap layout: 4096 addresses as a 64x64 grid, one row per 64 bytes
+const heatmapColumns = 64
+
+// IsCode reports whether the byte at address was reached as part of an instruction.
+func (g *CFG) IsCode(address uint16) bool {
+	for _, r := range g.Code {
+		if address >= r.Start && address < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteListing writes an annotated disassembly of a ROM loaded at the platform's load address.
+// Each instruction is prefixed with how often it was executed, and each byte of
+// data with how often it was read and written. Instructions that are reachable
+// but were never executed are marked with '!', which shows the branches a session
+// never took. Bytes that were executed but not found by the static analysis
+// (code behind indirect jumps) are listed as instructions too.
+func WriteListing(w io.Writer, rom []byte, platform cpu.Platform, coverage *cpu.Coverage) error {
+	bw := bufio.NewWriter(w)
+	g := Build(rom, platform)
+	start := platform.Descriptor().LoadAddress
+	end := start + uint16(len(rom))
+
+	// Summary of the reachable instructions
+	reachable, executed := 0, 0
+	for _, block := range g.Blocks {
+		for _, ins := range block.Instructions {
+			reachable++
+			if coverage.Executed[ins.Address] > 0 {
+				executed++
+			}
+		}
+	}
+	fmt.Fprintf(bw, "; %d of %d reachable instructions executed (%.1f%%)\n",
+		executed, reachable, percent(executed, reachable))
+	fmt.Fprintln(bw, ";")
+	fmt.Fprintln(bw, ";   addr  data   executed     read  written")
+
+	for address := start; address < end; {
+		executed := coverage.Executed[address]
+		if address+1 < end && (executed > 0 || g.IsCode(address)) {
+			opcode := uint16(rom[address-start])<<8 | uint16(rom[address-start+1])
+			mark := ' '
+			if executed == 0 {
+				mark = '!'
+			}
+			if _, ok := g.Block(address); ok {
+				fmt.Fprintf(bw, "%03X:\n", address)
+			}
+			fmt.Fprintf(bw, "%c   %03X  %04X  %9s  %7s  %7s  %s\n", mark, address, opcode,
+				count(executed), count(coverage.Read[address]), count(coverage.Written[address]),
+				cpu.Disassemble(opcode))
+			address += 2
+			continue
+		}
+		fmt.Fprintf(bw, "    %03X  %02X    %9s  %7s  %7s  DB 0x%02X\n", address, rom[address-start],
+			"", count(coverage.Read[address]), count(coverage.Written[address]), rom[address-start])
+		address++
+	}
+	return bw.Flush()
+}
+
+// count formats an access count, leaving zero counts blank
+func count(n uint64) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// WriteHeatmap writes a PNG image of the 4K address space, 64 bytes per row.
+// Executed addresses are green, read addresses blue and written addresses red,
+// brighter the more often they were accessed. The ROM's area is shaded grey.
+// Parameters:
+//   - w: The writer the PNG is written to
+//   - coverage: The recorded accesses
+//   - platform: The platform the ROM ran on, which decides where it was loaded
+//   - romSize: The size of the ROM
+//   - scale: The size of each address in image pixels
+func WriteHeatmap(w io.Writer, coverage *cpu.Coverage, platform cpu.Platform, romSize, scale int) error {
+	start := int(platform.Descriptor().LoadAddress)
+	rows := len(coverage.Executed) / heatmapColumns
+	img := image.NewRGBA(image.Rect(0, 0, heatmapColumns*scale, rows*scale))
+
+	maxExecuted, maxRead, maxWritten := maxCount(&coverage.Executed), maxCount(&coverage.Read), maxCount(&coverage.Written)
+	for address := range coverage.Executed {
+		c := color.RGBA{A: 0xFF}
+		if address >= start && address < start+romSize {
+			c.R, c.G, c.B = 0x30, 0x30, 0x30
+		}
+		c.R = heat(c.R, coverage.Written[address], maxWritten)
+		c.G = heat(c.G, coverage.Executed[address], maxExecuted)
+		c.B = heat(c.B, coverage.Read[address], maxRead)
+
+		x, y := address%heatmapColumns*scale, address/heatmapColumns*scale
+		for dy := 0; dy < scale; dy++ {
+			for dx := 0; dx < scale; dx++ {
+				img.SetRGBA(x+dx, y+dy, c)
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+func maxCount(counts *[4096]uint64) uint64 {
+	var m uint64
+	for _, n := range counts {
+		m = max(m, n)
+	}
+	return m
+}
+
+// heat returns the brightness of a color channel for an access count.
+// Counts are scaled logarithmically, so a single access is still clearly visible
+// next to a hot loop.
+func heat(base uint8, n, maxN uint64) uint8 {
+	if n == 0 {
+		return base
+	}
+	level := math.Log1p(float64(n)) / math.Log1p(float64(maxN))
+	return uint8(0x60 + level*0x9F)
+}
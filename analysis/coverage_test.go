@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"bytes"
+	"go-r8t/cpu"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestWriteListing(t *testing.T) {
+	rom := append(assemble(
+		0x3000, // 200: SE V0, 0
+		0x6001, // 202: LD V0, 1, skipped
+		0xA208, // 204: LD I, 208
+		0x1206, // 206: JP 206
+	), 0xF0)
+	coverage := cpu.NewCoverage()
+	coverage.Executed[0x200] = 1
+	coverage.Executed[0x204] = 1
+	coverage.Executed[0x206] = 100
+	coverage.Read[0x208] = 3
+
+	var buf bytes.Buffer
+	if err := WriteListing(&buf, rom, cpu.PlatformCHIP8, coverage); err != nil {
+		t.Fatal(err)
+	}
+	listing := buf.String()
+	for _, want := range []string{
+		"; 3 of 4 reachable instructions executed (75.0%)",
+		"!   202  6001",
+		"    206  1206        100",
+		"    208  F0",
+		"DB 0xF0",
+	} {
+		if !strings.Contains(listing, want) {
+			t.Errorf("listing doesn't contain %q:\n%s", want, listing)
+		}
+	}
+}
+
+func TestWriteHeatmap(t *testing.T) {
+	coverage := cpu.NewCoverage()
+	coverage.Executed[0x200] = 10
+	coverage.Read[0x201] = 1
+	coverage.Written[0xFFF] = 1
+
+	var buf bytes.Buffer
+	if err := WriteHeatmap(&buf, coverage, cpu.PlatformCHIP8, 4, 2); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 128 || size.Y != 128 {
+		t.Fatalf("heatmap is %v, want 128x128", size)
+	}
+
+	// pixel returns the color channels of an address
+	pixel := func(address int) (r, g, b uint32) {
+		r, g, b, _ = img.At(address%64*2, address/64*2).RGBA()
+		return r >> 8, g >> 8, b >> 8
+	}
+	tests := []struct {
+		address int
+		r, g, b uint32
+	}{
+		{0x000, 0x00, 0x00, 0x00}, // Outside the ROM, never accessed
+		{0x203, 0x30, 0x30, 0x30}, // In the ROM, never accessed
+		{0x200, 0x30, 0xFF, 0x30}, // Executed the most
+		{0x201, 0x30, 0x30, 0xFF}, // Read
+		{0xFFF, 0xFF, 0x00, 0x00}, // Written outside the ROM
+	}
+	for _, tt := range tests {
+		if r, g, b := pixel(tt.address); r != tt.r || g != tt.g || b != tt.b {
+			t.Errorf("address %03X is #%02X%02X%02X, want #%02X%02X%02X", tt.address, r, g, b, tt.r, tt.g, tt.b)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Graphviz styles of the edge kinds
+var edgeStyles = map[EdgeKind]string{
+	EdgeFallthrough: "",
+	EdgeJump:        `color="blue"`,
+	EdgeCall:        `color="darkgreen", style="bold"`,
+	EdgeReturn:      `style="dashed"`,
+	EdgeSkip:        `color="red"`,
+}
+
+// WriteDOT writes the graph in Graphviz DOT format.
+// Each block is a node listing its instructions; subroutine entries are
+// drawn as double octagons and indirect jumps are highlighted.
+func (g *CFG) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	subroutines := make(map[uint16]bool)
+	for _, address := range g.Subroutines {
+		subroutines[address] = true
+	}
+
+	fmt.Fprintln(bw, "digraph cfg {")
+	fmt.Fprintln(bw, `	node [shape="box", fontname="monospace"];`)
+	for _, block := range g.Blocks {
+		var label strings.Builder
+		for _, ins := range block.Instructions {
+			fmt.Fprintf(&label, "%03X: %04X  %s\\l", ins.Address, ins.Opcode, ins.Mnemonic)
+		}
+		var attrs []string
+		switch {
+		case block.Start == g.Entry:
+			attrs = append(attrs, `style="bold"`)
+		case subroutines[block.Start]:
+			attrs = append(attrs, `shape="doubleoctagon"`)
+		}
+		if block.Indirect {
+			attrs = append(attrs, `color="orange"`, `xlabel="indirect jump"`)
+		}
+		attrs = append(attrs, fmt.Sprintf(`label="%s"`, label.String()))
+		fmt.Fprintf(bw, "\tb%03X [%s];\n", block.Start, strings.Join(attrs, ", "))
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(bw, "\tb%03X -> b%03X", edge.From, edge.To)
+		if style := edgeStyles[edge.Kind]; style != "" {
+			fmt.Fprintf(bw, " [%s]", style)
+		}
+		fmt.Fprintln(bw, ";")
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteJSON writes the graph as indented JSON.
+func (g *CFG) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"go-r8t/keymap"
+)
+
+// Key used by both frontends to start binding keys interactively
+const bindKeyName = "tab"
+
+// Keymap configuration shared by both frontends
+var (
+	keyConfig     = &keymap.Config{}
+	keyConfigPath = keymap.DefaultConfigPath()
+	activeKeymap  = keymap.Presets[keymap.DefaultPreset].Keymap()
+	activeGamepad = keymap.DefaultGamepad
+)
+
+// Status line shown by the frontends, e.g. while binding keys
+var statusMessage string
+
+// SetStatus sets the status line shown below the display
+func SetStatus(message string) {
+	statusMessage = message
+}
+
+// LoadKeymap loads the keymap configuration file and resolves the keymap for the current ROM
+func LoadKeymap(path string) error {
+	config, err := keymap.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	keyConfig = config
+	keyConfigPath = path
+	return reloadKeymap()
+}
+
+// reloadKeymap resolves the active keymap, taking the current ROM's overrides into account
+func reloadKeymap() error {
+	k, err := keyConfig.Keymap(currentROMProfile(), romDefaults)
+	if err != nil {
+		return err
+	}
+	activeKeymap = k
+	activeGamepad = keyConfig.GamepadBindings(currentROMProfile(), romDefaults)
+	return nil
+}
+
+// currentROMProfile returns the name under which per-ROM settings are stored
+func currentROMProfile() string {
+	return currentROMFile
+}
+
+// bindSession runs the interactive "press a key to bind" flow
+type bindSession struct {
+	binder *keymap.Binder
+}
+
+// startBinding starts a binding session and shows its prompt
+func startBinding() *bindSession {
+	s := &bindSession{binder: keymap.NewBinder()}
+	s.prompt()
+	return s
+}
+
+// HandleKey processes a key press during the session.
+// It returns true when the session is over.
+func (s *bindSession) HandleKey(name string) bool {
+	switch name {
+	case bindKeyName:
+		return false
+	case "escape":
+		SetStatus("Key binding cancelled")
+		return true
+	case "backspace":
+		if !s.binder.Skip() {
+			s.prompt()
+			return false
+		}
+	default:
+		if !s.binder.Bind(name) {
+			s.prompt()
+			return false
+		}
+	}
+
+	s.save()
+	return true
+}
+
+// prompt shows which CHIP-8 key is waiting for a binding
+func (s *bindSession) prompt() {
+	key, _ := s.binder.Current()
+	SetStatus(fmt.Sprintf("Press a key for CHIP-8 key %X (Backspace: skip, ESC: cancel)", key))
+}
+
+// save stores the bindings as overrides for the current ROM, or globally if no ROM is loaded
+func (s *bindSession) save() {
+	if rom := currentROMProfile(); rom != "" {
+		keyConfig.SetROMBindings(rom, s.binder.Bindings())
+	} else {
+		keyConfig.Keys = keyConfig.Keys.Merge(s.binder.Bindings())
+	}
+
+	if err := keyConfig.Save(keyConfigPath); err != nil {
+		SetStatus("Failed to save keymap: " + err.Error())
+		return
+	}
+	if err := reloadKeymap(); err != nil {
+		SetStatus(err.Error())
+		return
+	}
+	SetStatus("Keymap saved to " + keyConfigPath)
+}
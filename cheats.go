@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"go-r8t/cheats"
+	"go-r8t/cpu"
+	"strconv"
+	"strings"
+)
+
+// Key used by both frontends to open the cheat console
+const consoleKeyName = "`"
+
+// Number of search candidates shown after each search step
+const shownCandidates = 6
+
+// Cheat state shared by both frontends
+var (
+	cheatConfig     = &cheats.Config{ROMs: make(map[string]cheats.List)}
+	cheatConfigPath = cheats.DefaultConfigPath()
+	activeCheats    cheats.List    // Cheats of the loaded ROM, applied every frame
+	cheatSearch     *cheats.Search // Memory search in progress, if any
+)
+
+// LoadCheats loads the cheat file
+func LoadCheats(path string) error {
+	config, err := cheats.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	cheatConfig = config
+	cheatConfigPath = path
+	return nil
+}
+
+// consoleSession is the cheat console's command line.
+// The game is paused while it is open.
+type consoleSession struct {
+	chip8 *cpu.CPU
+	line  string
+}
+
+// startConsole opens the cheat console and shows its prompt
+func startConsole(chip8 *cpu.CPU) *consoleSession {
+	s := &consoleSession{chip8: chip8}
+	s.prompt()
+	return s
+}
+
+// HandleKey processes a key press while the console is open.
+// It returns true when the console is closed.
+func (s *consoleSession) HandleKey(name string) bool {
+	switch name {
+	case consoleKeyName:
+		if s.line == "" {
+			SetStatus("")
+			return true
+		}
+	case "escape":
+		SetStatus("")
+		return true
+	case "enter":
+		SetStatus(runCheatCommand(s.chip8, s.line))
+		return true
+	case "backspace":
+		if s.line != "" {
+			s.line = s.line[:len(s.line)-1]
+		}
+	case "space":
+		s.line += " "
+	default:
+		if len([]rune(name)) == 1 {
+			s.line += name
+		}
+	}
+	s.prompt()
+	return false
+}
+
+// prompt shows the command line being typed
+func (s *consoleSession) prompt() {
+	SetStatus("cheat> " + s.line + "_")
+}
+
+// runCheatCommand executes a cheat console command and returns the message to show.
+//
+//	search                      start a new search over memory and V0-VF
+//	eq VALUE                    keep the candidates holding VALUE
+//	changed, unchanged          keep the candidates that changed (or not) since the last step
+//	inc, dec                    keep the candidates that increased (decreased) since the last step
+//	freeze TARGET [VALUE] NAME  freeze a target, to its current value by default
+//	unfreeze TARGET             remove the cheat for a target
+//	toggle TARGET               enable or disable the cheat for a target
+//	list                        list the cheats of the ROM
+func runCheatCommand(chip8 *cpu.CPU, line string) string {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return ""
+	}
+
+	// Only the command is case-insensitive; cheat names are kept as typed
+	switch command := strings.ToLower(args[0]); command {
+	case "search":
+		cheatSearch = cheats.NewSearch(chip8)
+		return fmt.Sprintf("Search started with %d candidates", len(cheatSearch.Candidates()))
+	case "eq", "changed", "unchanged", "inc", "dec":
+		comparison, _ := cheats.ParseComparison(command)
+		var value byte
+		if comparison == cheats.Equal {
+			if len(args) < 2 {
+				return "Usage: eq VALUE"
+			}
+			v, err := parseCheatValue(args[1])
+			if err != nil {
+				return err.Error()
+			}
+			value = v
+		}
+		if cheatSearch == nil {
+			// Changes are relative to the start of the search, so only eq can start one
+			cheatSearch = cheats.NewSearch(chip8)
+			if comparison != cheats.Equal {
+				return "Search started; play on and run " + command + " again"
+			}
+		}
+		cheatSearch.Filter(chip8, comparison, value)
+		return describeCandidates(chip8, cheatSearch.Candidates())
+	case "freeze":
+		if len(args) < 2 {
+			return "Usage: freeze TARGET [VALUE] [NAME]"
+		}
+		target, err := cheats.ParseTarget(args[1])
+		if err != nil {
+			return err.Error()
+		}
+		cheat := cheats.Cheat{Target: target, Value: target.Get(chip8)}
+		names := args[2:]
+		if len(names) > 0 {
+			if v, err := parseCheatValue(names[0]); err == nil {
+				cheat.Value = v
+				names = names[1:]
+			}
+		}
+		cheat.Name = strings.Join(names, " ")
+		activeCheats = activeCheats.Freeze(cheat)
+		return saveCheats(fmt.Sprintf("Froze %s at %d", target, cheat.Value))
+	case "unfreeze", "toggle":
+		if len(args) < 2 {
+			return "Usage: " + command + " TARGET"
+		}
+		target, err := cheats.ParseTarget(args[1])
+		if err != nil {
+			return err.Error()
+		}
+		for i, cheat := range activeCheats {
+			if cheat.Target != target {
+				continue
+			}
+			if command == "unfreeze" {
+				activeCheats = activeCheats.Remove(target)
+				return saveCheats("Unfroze " + target.String())
+			}
+			activeCheats[i].Disabled = !cheat.Disabled
+			if activeCheats[i].Disabled {
+				return saveCheats("Disabled " + target.String())
+			}
+			return saveCheats("Enabled " + target.String())
+		}
+		return "No cheat for " + target.String()
+	case "list":
+		if len(activeCheats) == 0 {
+			return "No cheats for this ROM"
+		}
+		var parts []string
+		for _, cheat := range activeCheats {
+			part := fmt.Sprintf("%s=%d", cheat.Target, cheat.Value)
+			if cheat.Name != "" {
+				part = cheat.Name + " " + part
+			}
+			if cheat.Disabled {
+				part += " (off)"
+			}
+			parts = append(parts, part)
+		}
+		return "Cheats: " + strings.Join(parts, ", ")
+	default:
+		return fmt.Sprintf("Unknown cheat command %q", command)
+	}
+}
+
+// parseCheatValue parses a byte value in decimal or, with a 0x prefix, hexadecimal
+func parseCheatValue(s string) (byte, error) {
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q (expected 0-255)", s)
+	}
+	return byte(v), nil
+}
+
+// describeCandidates lists the first search candidates and their current values
+func describeCandidates(chip8 *cpu.CPU, candidates []cheats.Target) string {
+	if len(candidates) == 0 {
+		return "No candidates left; run search to start over"
+	}
+	var parts []string
+	for i, target := range candidates {
+		if i == shownCandidates {
+			parts = append(parts, "...")
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s=%d", target, target.Get(chip8)))
+	}
+	return fmt.Sprintf("%d candidates: %s", len(candidates), strings.Join(parts, " "))
+}
+
+// saveCheats stores the cheats of the loaded ROM and returns message, or the error if saving failed
+func saveCheats(message string) string {
+	cheatConfig.Set(currentROMHash, activeCheats)
+	if err := cheatConfig.Save(cheatConfigPath); err != nil {
+		return "Failed to save cheats: " + err.Error()
+	}
+	return message
+}
@@ -0,0 +1,171 @@
+// Package cheats searches CHIP-8 memory and registers for game state such as
+// lives counters and freezes them to fixed values.
+package cheats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-r8t/cpu"
+	"go-r8t/userdata"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Target is a byte of CPU state a cheat can read and freeze: a memory address or a V register.
+type Target struct {
+	Register bool   // V register instead of a memory address
+	Index    uint16 // Register number or memory address
+}
+
+// ParseTarget parses a target: V0-VF for a register, or a memory address in
+// hexadecimal, with or without a 0x prefix.
+func ParseTarget(s string) (Target, error) {
+	s = strings.ToLower(s)
+	if len(s) == 2 && s[0] == 'v' {
+		if n, err := strconv.ParseUint(s[1:], 16, 4); err == nil {
+			return Target{Register: true, Index: uint16(n)}, nil
+		}
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 12)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid cheat target %q (expected V0-VF or a memory address)", s)
+	}
+	return Target{Index: uint16(n)}, nil
+}
+
+// String returns the target in the form ParseTarget accepts.
+func (t Target) String() string {
+	if t.Register {
+		return fmt.Sprintf("V%X", t.Index)
+	}
+	return fmt.Sprintf("0x%03X", t.Index)
+}
+
+// Get returns the current value of the target.
+func (t Target) Get(chip8 *cpu.CPU) byte {
+	if t.Register {
+		return chip8.V[t.Index&0xF]
+	}
+	return chip8.Read8(t.Index & 0xFFF)
+}
+
+// Set changes the value of the target.
+func (t Target) Set(chip8 *cpu.CPU, value byte) {
+	if t.Register {
+		chip8.V[t.Index&0xF] = value
+	} else {
+		chip8.Write8(t.Index&0xFFF, value)
+	}
+}
+
+// MarshalText encodes the target as a string, so it can be used as a JSON value.
+func (t Target) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a target written by MarshalText.
+func (t *Target) UnmarshalText(text []byte) error {
+	parsed, err := ParseTarget(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Cheat freezes a target to a fixed value.
+type Cheat struct {
+	Name     string `json:"name,omitempty"`
+	Target   Target `json:"target"`
+	Value    byte   `json:"value"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// List is the set of cheats of a ROM.
+type List []Cheat
+
+// Apply writes the frozen values of the enabled cheats.
+// It is called every frame, so the program can't change them for long.
+func (l List) Apply(chip8 *cpu.CPU) {
+	for _, cheat := range l {
+		if !cheat.Disabled {
+			cheat.Target.Set(chip8, cheat.Value)
+		}
+	}
+}
+
+// Freeze adds a cheat, replacing any cheat for the same target.
+func (l List) Freeze(cheat Cheat) List {
+	l = l.Remove(cheat.Target)
+	return append(l, cheat)
+}
+
+// Remove deletes the cheat for a target.
+func (l List) Remove(target Target) List {
+	kept := l[:0:0]
+	for _, cheat := range l {
+		if cheat.Target != target {
+			kept = append(kept, cheat)
+		}
+	}
+	return kept
+}
+
+// Config is the cheat file, holding the cheats of every ROM.
+//
+// Example:
+//
+//	{
+//	  "roms": {
+//	    "<sha1>": [{"name": "Lives", "target": "0x3F2", "value": 3}]
+//	  }
+//	}
+type Config struct {
+	ROMs map[string]List `json:"roms"` // Cheats keyed by the ROM's SHA-1
+}
+
+// DefaultConfigPath returns the location of the cheat file.
+func DefaultConfigPath() string {
+	return userdata.Path("cheats.json")
+}
+
+// LoadConfig reads a cheat file.
+// A missing file is not an error and yields an empty configuration.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{ROMs: make(map[string]List)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if config.ROMs == nil {
+		config.ROMs = make(map[string]List)
+	}
+	return config, nil
+}
+
+// Save writes the cheat file, creating its directory if needed.
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return userdata.WriteFile(path, append(data, '\n'))
+}
+
+// Set stores the cheats of a ROM, removing its entry when the list is empty.
+func (c *Config) Set(sha1 string, cheats List) {
+	if len(cheats) == 0 {
+		delete(c.ROMs, sha1)
+		return
+	}
+	c.ROMs[sha1] = cheats
+}
@@ -0,0 +1,125 @@
+package cheats
+
+import (
+	"go-r8t/cpu"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Target
+		wantErr bool
+	}{
+		{in: "V0", want: Target{Register: true, Index: 0}},
+		{in: "vf", want: Target{Register: true, Index: 0xF}},
+		{in: "0x3F2", want: Target{Index: 0x3F2}},
+		{in: "3f2", want: Target{Index: 0x3F2}},
+		{in: "FFF", want: Target{Index: 0xFFF}},
+		{in: "1000", wantErr: true},
+		{in: "vg", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTarget(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTarget(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTarget(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+			continue
+		}
+		// String returns a form ParseTarget accepts
+		if again, err := ParseTarget(got.String()); err != nil || again != got {
+			t.Errorf("ParseTarget(%q) = %v, %v; want %v", got.String(), again, err, got)
+		}
+	}
+}
+
+func TestSearchFilter(t *testing.T) {
+	chip8 := cpu.NewCPU()
+	lives := Target{Index: 0x300}
+	score := Target{Register: true, Index: 0x5}
+	lives.Set(chip8, 3)
+	score.Set(chip8, 10)
+
+	s := NewSearch(chip8)
+	if n := len(s.Candidates()); n != 16+len(chip8.Memory) {
+		t.Fatalf("new search has %d candidates, want %d", n, 16+len(chip8.Memory))
+	}
+
+	// Lives are lost and the score goes up
+	lives.Set(chip8, 2)
+	score.Set(chip8, 20)
+	s.Filter(chip8, Changed, 0)
+	if got := s.Candidates(); len(got) != 2 || got[0] != score || got[1] != lives {
+		t.Fatalf("changed candidates = %v, want [%v %v]", got, score, lives)
+	}
+	s.Filter(chip8, Unchanged, 0)
+	if n := len(s.Candidates()); n != 2 {
+		t.Fatalf("unchanged left %d candidates, want 2", n)
+	}
+
+	lives.Set(chip8, 1)
+	score.Set(chip8, 30)
+	if n := s.Filter(chip8, Decreased, 0); n != 1 || s.Candidates()[0] != lives {
+		t.Fatalf("decreased candidates = %v, want [%v]", s.Candidates(), lives)
+	}
+	if n := s.Filter(chip8, Equal, 1); n != 1 {
+		t.Fatalf("eq 1 left %d candidates, want 1", n)
+	}
+	if n := s.Filter(chip8, Equal, 2); n != 0 {
+		t.Fatalf("eq 2 left %d candidates, want 0", n)
+	}
+}
+
+func TestSearchIncreased(t *testing.T) {
+	chip8 := cpu.NewCPU()
+	s := NewSearch(chip8)
+	chip8.V[3] = 1
+	if n := s.Filter(chip8, Increased, 0); n != 1 || s.Candidates()[0] != (Target{Register: true, Index: 3}) {
+		t.Fatalf("increased candidates = %v, want [V3]", s.Candidates())
+	}
+}
+
+func TestFreezeRemove(t *testing.T) {
+	v0 := Target{Register: true, Index: 0}
+	mem := Target{Index: 0x3F2}
+
+	var l List
+	l = l.Freeze(Cheat{Name: "Lives", Target: mem, Value: 3})
+	l = l.Freeze(Cheat{Target: v0, Value: 1})
+	l = l.Freeze(Cheat{Name: "More lives", Target: mem, Value: 9})
+	if len(l) != 2 || l[0].Target != v0 || l[1].Value != 9 || l[1].Name != "More lives" {
+		t.Fatalf("after freezing the same target twice: %+v", l)
+	}
+
+	chip8 := cpu.NewCPU()
+	l.Apply(chip8)
+	if chip8.V[0] != 1 || chip8.Memory[0x3F2] != 9 {
+		t.Fatalf("Apply wrote V0 = %d, [3F2] = %d; want 1, 9", chip8.V[0], chip8.Memory[0x3F2])
+	}
+
+	// Disabled cheats are kept but not applied
+	l[0].Disabled = true
+	chip8.V[0] = 7
+	l.Apply(chip8)
+	if chip8.V[0] != 7 {
+		t.Errorf("disabled cheat wrote V0 = %d", chip8.V[0])
+	}
+
+	// Remove doesn't modify the list it was called on
+	removed := l.Remove(mem)
+	if len(removed) != 1 || removed[0].Target != v0 {
+		t.Fatalf("after Remove: %+v", removed)
+	}
+	if len(l) != 2 || l[1].Target != mem {
+		t.Fatalf("Remove changed the original list: %+v", l)
+	}
+	if len(removed.Remove(mem)) != 1 {
+		t.Errorf("removing a missing target changed the list")
+	}
+}
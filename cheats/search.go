@@ -0,0 +1,109 @@
+package cheats
+
+import (
+	"fmt"
+	"go-r8t/cpu"
+)
+
+// Comparison selects which candidates a search step keeps.
+type Comparison int
+
+const (
+	Equal     Comparison = iota // The value equals the given value
+	Changed                     // The value differs from the previous snapshot
+	Unchanged                   // The value is the same as in the previous snapshot
+	Increased                   // The value is greater than in the previous snapshot
+	Decreased                   // The value is less than in the previous snapshot
+)
+
+// Names of the comparisons, as used by the cheat console
+var comparisonNames = map[string]Comparison{
+	"eq":        Equal,
+	"changed":   Changed,
+	"unchanged": Unchanged,
+	"inc":       Increased,
+	"dec":       Decreased,
+}
+
+// ParseComparison converts a comparison name (eq, changed, unchanged, inc, dec) into a Comparison.
+func ParseComparison(name string) (Comparison, error) {
+	if c, ok := comparisonNames[name]; ok {
+		return c, nil
+	}
+	return Equal, fmt.Errorf("unknown comparison %q", name)
+}
+
+// Search narrows down the targets that hold a piece of game state.
+// It starts with every memory address and register; each step compares them
+// with a value or with the snapshot taken by the previous step.
+type Search struct {
+	candidates []Target
+	memory     [4096]byte // Snapshot of the memory at the last step
+	registers  [16]byte   // Snapshot of the registers at the last step
+}
+
+// NewSearch starts a search over all of memory and the V registers.
+func NewSearch(chip8 *cpu.CPU) *Search {
+	s := &Search{}
+	for i := range chip8.V {
+		s.candidates = append(s.candidates, Target{Register: true, Index: uint16(i)})
+	}
+	for address := range chip8.Memory {
+		s.candidates = append(s.candidates, Target{Index: uint16(address)})
+	}
+	s.snapshot(chip8)
+	return s
+}
+
+// Filter keeps the candidates that satisfy the comparison and takes a new snapshot.
+// It returns the number of candidates left.
+// Parameters:
+//   - chip8: The CPU holding the current values
+//   - comparison: The condition the candidates must satisfy
+//   - value: The value to compare with, for Equal
+func (s *Search) Filter(chip8 *cpu.CPU, comparison Comparison, value byte) int {
+	kept := s.candidates[:0]
+	for _, target := range s.candidates {
+		current, previous := target.Get(chip8), s.previous(target)
+		var keep bool
+		switch comparison {
+		case Equal:
+			keep = current == value
+		case Changed:
+			keep = current != previous
+		case Unchanged:
+			keep = current == previous
+		case Increased:
+			keep = current > previous
+		case Decreased:
+			keep = current < previous
+		}
+		if keep {
+			kept = append(kept, target)
+		}
+	}
+	s.candidates = kept
+	s.snapshot(chip8)
+	return len(s.candidates)
+}
+
+// Candidates returns the targets that satisfied every step so far.
+func (s *Search) Candidates() []Target {
+	return s.candidates
+}
+
+// snapshot remembers the current values for the next comparison
+func (s *Search) snapshot(chip8 *cpu.CPU) {
+	for address := range s.memory {
+		s.memory[address] = chip8.Read8(uint16(address))
+	}
+	s.registers = chip8.V
+}
+
+// previous returns the value of a target in the last snapshot
+func (s *Search) previous(target Target) byte {
+	if target.Register {
+		return s.registers[target.Index&0xF]
+	}
+	return s.memory[target.Index&0xFFF]
+}
@@ -0,0 +1,279 @@
+// Package chip8 is an embeddable CHIP-8 emulator.
+//
+// It runs a ROM at a fixed frame rate and reports every frame through
+// callbacks, without any dependency on a graphics or terminal library:
+//
+//	emu := chip8.New(chip8.WithFrameCallback(func(f chip8.Frame) { draw(f) }))
+//	if err := emu.LoadROM(rom); err != nil { ... }
+//	go emu.Run(ctx)
+//	emu.PressKey(0x5)
+//
+// All methods are safe to call from any goroutine. Key events are queued and
+// applied between frames, so a frame always sees a consistent keypad.
+package chip8
+
+import (
+	"context"
+	"errors"
+	"go-r8t/cpu"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrNoROM is returned by Run when no ROM has been loaded.
+var ErrNoROM = errors.New("chip8: no ROM loaded")
+
+// Frame is the state of the display after a frame has been executed.
+type Frame struct {
+	Number uint64 // Frames executed since the ROM was loaded, starting at 1
+	Width  int    // Display width in pixels (64, or 128 in SCHIP hires mode)
+	Height int    // Display height in pixels (32 on most platforms, or 64 in SCHIP hires mode)
+	Pixels []byte // One byte per pixel (0 or 1), row by row; owned by the callback
+	Sound  bool   // Whether the sound timer is running
+
+	// Regions of the display the frame changed. It is empty when the display
+	// is the same as in the previous frame, so consumers can skip the frame.
+	Dirty []cpu.Rect
+}
+
+// keyEvent is a queued key press or release
+type keyEvent struct {
+	key     uint8
+	pressed bool
+}
+
+// Emulator runs a CHIP-8 program.
+type Emulator struct {
+	mu       sync.Mutex
+	cpu      *cpu.CPU
+	loaded   bool
+	paused   bool
+	resumed  chan struct{} // Closed and replaced by Resume to wake up a paused Run
+	keys     []keyEvent    // Key events waiting for the next frame
+	tickrate int           // Instructions per frame of the loaded ROM
+	settings settings
+}
+
+// New creates an emulator. Call LoadROM before Run.
+func New(opts ...Option) *Emulator {
+	e := &Emulator{
+		cpu:     cpu.NewCPU(),
+		resumed: make(chan struct{}),
+		settings: settings{
+			frameRate: DefaultFrameRate,
+		},
+	}
+	for _, opt := range opts {
+		opt(&e.settings)
+	}
+	return e
+}
+
+// LoadROM reads a program and resets the emulator to run it.
+// The program is set up like in the emulator's own frontends; see Setup.
+func (e *Emulator) LoadROM(r io.Reader) error {
+	program, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c := cpu.NewCPU()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	romSettings, err := e.settings.setup(c, "", program, program)
+	if err != nil {
+		return err
+	}
+
+	e.cpu = c
+	e.tickrate = romSettings.Tickrate
+	e.loaded = true
+	e.keys = nil
+	return nil
+}
+
+// Run executes frames at the configured frame rate until the context is
+// cancelled, the program exits with the SCHIP 00FD instruction or it faults.
+// It returns nil when the program exits, the *cpu.Fault when it faults and
+// the context's error otherwise.
+func (e *Emulator) Run(ctx context.Context) error {
+	e.mu.Lock()
+	loaded, frameRate := e.loaded, e.settings.frameRate
+	e.mu.Unlock()
+	if !loaded {
+		return ErrNoROM
+	}
+
+	var tick <-chan time.Time
+	if frameRate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(frameRate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		// Wait while paused
+		e.mu.Lock()
+		paused, resumed := e.paused, e.resumed
+		e.mu.Unlock()
+		if paused {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-resumed:
+			}
+			continue
+		}
+
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-tick:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, exited := e.RunFrame(); exited {
+			return e.Fault()
+		}
+	}
+}
+
+// RunFrame applies the queued key events, executes one frame and calls the
+// frame callbacks. It returns the frame and whether the program has exited
+// or faulted.
+// Run calls it at the frame rate; call it directly to step a paused emulator
+// or to run without a clock, e.g. in tests.
+func (e *Emulator) RunFrame() (Frame, bool) {
+	e.mu.Lock()
+	e.applyKeys()
+
+	ExecuteFrame(e.cpu, e.tickrate, nil)
+	frame := e.snapshot()
+	frame.Dirty = e.cpu.TakeDirty()
+	exited := e.cpu.Exited || e.cpu.Fault != nil
+	callbacks := e.settings.callbacks
+	e.mu.Unlock()
+
+	// Callbacks run without the lock, so they can call back into the emulator
+	for _, callback := range callbacks {
+		callback(frame)
+	}
+	return frame, exited
+}
+
+// Fault returns the memory fault that stopped the program, or nil.
+// Faults are only raised with the cpu.MemoryFault policy.
+func (e *Emulator) Fault() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cpu.Fault == nil {
+		return nil
+	}
+	return e.cpu.Fault
+}
+
+// Snapshot returns the current display without executing anything.
+func (e *Emulator) Snapshot() Frame {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.snapshot()
+}
+
+// snapshot copies the display; e.mu must be held
+func (e *Emulator) snapshot() Frame {
+	display := e.cpu.Snapshot()
+	return Frame{
+		Number: e.cpu.Frame,
+		Width:  display.Width,
+		Height: display.Height,
+		Pixels: display.Pixels,
+		Sound:  e.cpu.SoundTimer > 0,
+	}
+}
+
+// Pause stops Run after the current frame, until Resume is called.
+func (e *Emulator) Pause() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paused = true
+}
+
+// Resume continues a paused Run.
+func (e *Emulator) Resume() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.paused {
+		e.paused = false
+		close(e.resumed)
+		e.resumed = make(chan struct{})
+	}
+}
+
+// Paused reports whether the emulator is paused.
+func (e *Emulator) Paused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paused
+}
+
+// PressKey queues a press of a keypad key (0x0-0xF) for the next frame.
+func (e *Emulator) PressKey(key uint8) {
+	e.queueKey(key, true)
+}
+
+// ReleaseKey queues a release of a keypad key (0x0-0xF) for the next frame.
+func (e *Emulator) ReleaseKey(key uint8) {
+	e.queueKey(key, false)
+}
+
+// SetKeys queues the state of the whole keypad for the next frame.
+func (e *Emulator) SetKeys(keys [16]bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, pressed := range keys {
+		e.keys = append(e.keys, keyEvent{uint8(key), pressed})
+	}
+}
+
+// applyKeys applies the queued key events to the keypad; e.mu must be held.
+// A key that is pressed and released within the same frame stays pressed for
+// that frame: its release and the events after it wait for the next frame,
+// so a quick tap is never lost.
+func (e *Emulator) applyKeys() {
+	var pressed [16]bool
+	for i, ev := range e.keys {
+		if !ev.pressed && ev.key < 16 && pressed[ev.key] {
+			e.keys = append(e.keys[:0], e.keys[i:]...)
+			return
+		}
+		if ev.pressed && ev.key < 16 {
+			pressed[ev.key] = true
+		}
+		e.cpu.SetKey(ev.key, ev.pressed)
+	}
+	e.keys = e.keys[:0]
+}
+
+func (e *Emulator) queueKey(key uint8, pressed bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keys = append(e.keys, keyEvent{key, pressed})
+}
+
+// SaveState returns a snapshot of the emulated machine that LoadState can restore.
+func (e *Emulator) SaveState() ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cpu.SaveState()
+}
+
+// LoadState restores a snapshot taken by SaveState.
+func (e *Emulator) LoadState(data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cpu.LoadState(data)
+}
@@ -0,0 +1,223 @@
+package chip8
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-r8t/cpu"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Program that draws the digit in V0 forever, moving on to the next digit
+// while the key with that number is held:
+//
+//	200: 6000  LD V0, 0
+//	202: 610F  LD V1, 0F
+//	204: E09E  SKP V0
+//	206: 120C  JP 20C
+//	208: 7001  ADD V0, 1
+//	20A: 8012  AND V0, V1
+//	20C: F029  LD F, V0
+//	20E: D005  DRW V0, V0, 5
+//	210: 1204  JP 204
+var keyLoop = []byte{
+	0x60, 0x00, 0x61, 0x0F, 0xE0, 0x9E, 0x12, 0x0C, 0x70, 0x01,
+	0x80, 0x12, 0xF0, 0x29, 0xD0, 0x05, 0x12, 0x04,
+}
+
+// TestConcurrentAccess calls the emulator from several goroutines while Run
+// executes frames as fast as possible. Run it with -race.
+func TestConcurrentAccess(t *testing.T) {
+	var frames atomic.Int64
+	emu := New(
+		WithFrameRate(0),
+		WithFrameCallback(func(f Frame) {
+			frames.Add(1)
+			if len(f.Pixels) != f.Width*f.Height {
+				t.Errorf("frame %d has %d pixels, want %d", f.Number, len(f.Pixels), f.Width*f.Height)
+			}
+		}),
+	)
+	if err := emu.LoadROM(bytes.NewReader(keyLoop)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- emu.Run(ctx) }()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	worker := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					f(i)
+				}
+			}
+		}()
+	}
+	worker(func(i int) {
+		emu.PressKey(uint8(i % 16))
+		emu.ReleaseKey(uint8(i % 16))
+	})
+	worker(func(i int) {
+		var keys [16]bool
+		keys[i%16] = true
+		emu.SetKeys(keys)
+	})
+	worker(func(int) {
+		if f := emu.Snapshot(); len(f.Pixels) != f.Width*f.Height {
+			t.Errorf("snapshot has %d pixels, want %d", len(f.Pixels), f.Width*f.Height)
+		}
+	})
+	worker(func(int) {
+		emu.Pause()
+		emu.Paused()
+		emu.Resume()
+	})
+	worker(func(int) {
+		state, err := emu.SaveState()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := emu.LoadState(state); err != nil {
+			t.Error(err)
+		}
+	})
+
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	// Run must still be running after the last Resume, and stop when cancelled
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run returned %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after the context was cancelled")
+	}
+	if frames.Load() == 0 {
+		t.Error("no frames were executed")
+	}
+}
+
+func TestRunWithoutROM(t *testing.T) {
+	if err := New().Run(context.Background()); !errors.Is(err, ErrNoROM) {
+		t.Errorf("Run without a ROM returned %v, want %v", err, ErrNoROM)
+	}
+}
+
+func TestKeyTapLastsAFrame(t *testing.T) {
+	emu := New(WithFrameRate(0))
+	if err := emu.LoadROM(bytes.NewReader(keyLoop)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A key pressed and released between two frames is held for one frame
+	emu.PressKey(0x3)
+	emu.ReleaseKey(0x3)
+	emu.RunFrame()
+	emu.mu.Lock()
+	held := emu.cpu.Keys[0x3]
+	emu.mu.Unlock()
+	if !held {
+		t.Fatal("tapped key is not held during the first frame")
+	}
+	emu.RunFrame()
+	emu.mu.Lock()
+	held = emu.cpu.Keys[0x3]
+	emu.mu.Unlock()
+	if held {
+		t.Fatal("tapped key is still held in the second frame")
+	}
+}
+
+func TestFontAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want uint16
+	}{
+		{"CHIP-8", []Option{WithPlatform(cpu.PlatformCHIP8)}, 0x050},
+		{"XO-CHIP", []Option{WithPlatform(cpu.PlatformXOCHIP)}, 0x000},
+		{"WithFontAddress", []Option{WithPlatform(cpu.PlatformXOCHIP), WithFontAddress(0x100)}, 0x100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emu := New(append(tt.opts, WithFrameRate(0))...)
+			if err := emu.LoadROM(bytes.NewReader(keyLoop)); err != nil {
+				t.Fatal(err)
+			}
+			emu.RunFrame()
+			emu.mu.Lock()
+			defer emu.mu.Unlock()
+			c := emu.cpu
+			if c.FontAddress != tt.want {
+				t.Errorf("FontAddress = %#03x, want %#03x", c.FontAddress, tt.want)
+			}
+			// The digit 0 is the first character of the font
+			if c.Memory[tt.want] != 0xF0 || c.Memory[tt.want+4] != 0xF0 {
+				t.Errorf("digit 0 is not loaded at %#03x", tt.want)
+			}
+			if c.I != tt.want {
+				t.Errorf("F029 with V0 = 0 set I to %#03x, want %#03x", c.I, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomSeed(t *testing.T) {
+	// Draws the digit 0 at random positions:
+	//
+	//	200: F229  LD F, V2
+	//	202: C03F  RND V0, 3F
+	//	204: C11F  RND V1, 1F
+	//	206: D015  DRW V0, V1, 5
+	//	208: 1202  JP 202
+	program := []byte{0xF2, 0x29, 0xC0, 0x3F, 0xC1, 0x1F, 0xD0, 0x15, 0x12, 0x02}
+	pixels := func(seed uint64) []byte {
+		emu := New(WithRandomSeed(seed), WithFrameRate(0))
+		if err := emu.LoadROM(bytes.NewReader(program)); err != nil {
+			t.Fatal(err)
+		}
+		var frame Frame
+		for i := 0; i < 10; i++ {
+			frame, _ = emu.RunFrame()
+		}
+		return frame.Pixels
+	}
+	if !bytes.Equal(pixels(7), pixels(7)) {
+		t.Error("seed 7 drew different frames")
+	}
+	if bytes.Equal(pixels(7), pixels(8)) {
+		t.Error("seeds 7 and 8 drew the same frame")
+	}
+}
+
+func TestUnidentifiedROMFont(t *testing.T) {
+	// A ROM that isn't in the database gets the font of plain CHIP-8, like
+	// in the frontends
+	emu := New(WithFrameRate(0))
+	if err := emu.LoadROM(bytes.NewReader(keyLoop)); err != nil {
+		t.Fatal(err)
+	}
+	emu.mu.Lock()
+	defer emu.mu.Unlock()
+	if got, want := emu.cpu.Font().Name, cpu.NewCPU().Font().Name; got != want {
+		t.Errorf("font = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package chip8
+
+import (
+	"go-r8t/cpu"
+	"go-r8t/romdb"
+)
+
+// DefaultFrameRate is the frame rate of the CHIP-8 timers.
+const DefaultFrameRate = 60
+
+// settings are the emulator's configuration, set by options
+type settings struct {
+	platform    cpu.Platform
+	platformSet bool
+	quirks      *cpu.Quirks
+	tickrate    int
+	tickrateSet bool
+	memory      cpu.MemoryPolicy
+	font        *cpu.Font
+	fontAddress *uint16
+	seed        *uint64
+	frameRate   int
+	database    *romdb.Database
+	callbacks   []func(Frame)
+}
+
+// Option configures an Emulator.
+type Option func(*settings)
+
+// WithPlatform selects the platform to emulate, with its default quirks and tickrate.
+// It takes precedence over the ROM database.
+func WithPlatform(p cpu.Platform) Option {
+	return func(s *settings) {
+		s.platform = p
+		s.platformSet = true
+	}
+}
+
+// WithQuirks overrides the quirks of the platform.
+func WithQuirks(q cpu.Quirks) Option {
+	return func(s *settings) {
+		s.quirks = &q
+	}
+}
+
+// WithTickrate sets the number of instructions executed per frame.
+func WithTickrate(n int) Option {
+	return func(s *settings) {
+		s.tickrate = n
+		s.tickrateSet = true
+	}
+}
+
+// WithFont loads a font instead of the platform's, e.g. a custom one read with cpu.ReadFont.
+func WithFont(f cpu.Font) Option {
+	return func(s *settings) {
+		s.font = &f
+	}
+}
+
+// WithFontAddress loads the font at an address in the interpreter area
+// instead of the platform's font address.
+func WithFontAddress(address uint16) Option {
+	return func(s *settings) {
+		s.fontAddress = &address
+	}
+}
+
+// WithRandomSeed seeds the random bytes of CXNN, so that the program runs
+// the same way every time it is loaded and given the same input.
+func WithRandomSeed(seed uint64) Option {
+	return func(s *settings) {
+		s.seed = &seed
+	}
+}
+
+// WithMemoryPolicy selects what instructions do with addresses past the end
+// of memory. With cpu.MemoryFault, Run stops and returns the *cpu.Fault.
+func WithMemoryPolicy(p cpu.MemoryPolicy) Option {
+	return func(s *settings) {
+		s.memory = p
+	}
+}
+
+// WithFrameRate sets the number of frames Run executes per second.
+// A rate of 0 runs frames as fast as possible, e.g. for bots and tests.
+func WithFrameRate(hz int) Option {
+	return func(s *settings) {
+		s.frameRate = hz
+	}
+}
+
+// WithDatabase identifies loaded ROMs in a ROM database and applies their settings.
+func WithDatabase(db *romdb.Database) Option {
+	return func(s *settings) {
+		s.database = db
+	}
+}
+
+// WithFrameCallback adds a function that is called after every frame.
+// Callbacks run on the goroutine that executes the frames.
+func WithFrameCallback(f func(Frame)) Option {
+	return func(s *settings) {
+		s.callbacks = append(s.callbacks, f)
+	}
+}
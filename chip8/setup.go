@@ -0,0 +1,87 @@
+package chip8
+
+import (
+	"fmt"
+	"go-r8t/cpu"
+	"go-r8t/romdb"
+)
+
+// Setup prepares a new CPU to run a program, the way Emulator.LoadROM and
+// the emulator's own frontends do.
+// The program is identified with romdb.Identify: if the options include a
+// ROM database and the program is in it, the database's platform, quirks
+// and tickrate are used, and platforms whose programs can be recognized,
+// like hires CHIP-8, are detected otherwise. Unrecognized programs keep the
+// defaults of cpu.NewCPU. Options take precedence over both. The platform's
+// font is loaded unless one was set with WithFont, and the program is loaded
+// at the platform's load address.
+// Parameters:
+//   - c: A CPU created by cpu.NewCPU
+//   - name: The program's file name, used to guess its platform; may be empty
+//   - original: The program as distributed, used to identify it
+//   - program: The program to run, e.g. original with a patch applied
+//
+// It returns the settings the program runs with. Name, Palette and Keys are
+// the database's, even when the platform is overridden.
+func Setup(c *cpu.CPU, name string, original, program []byte, opts ...Option) (romdb.Settings, error) {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s.setup(c, name, original, program)
+}
+
+// setup applies the settings to c and loads the program; see Setup.
+func (s *settings) setup(c *cpu.CPU, name string, original, program []byte) (romdb.Settings, error) {
+	romSettings, ok := romdb.Identify(s.database, name, original)
+	if !ok {
+		romSettings = romdb.Settings{Platform: c.Platform, Quirks: c.Quirks, Tickrate: c.Platform.Tickrate()}
+	}
+	if s.platformSet {
+		romSettings.Platform, romSettings.Quirks, romSettings.Tickrate = s.platform, s.platform.Quirks(), s.platform.Tickrate()
+	}
+	if s.quirks != nil {
+		romSettings.Quirks = *s.quirks
+	}
+	if s.tickrateSet {
+		romSettings.Tickrate = s.tickrate
+	}
+	c.Platform = romSettings.Platform
+	c.Quirks = romSettings.Quirks
+	c.MemoryPolicy = s.memory
+	if s.seed != nil {
+		c.SeedRandom(*s.seed)
+	}
+
+	font := c.Platform.Font()
+	if s.font != nil {
+		font = *s.font
+	}
+	fontAddress := c.Platform.Descriptor().FontAddress
+	if s.fontAddress != nil {
+		fontAddress = *s.fontAddress
+	}
+	if err := c.SetFont(font, fontAddress); err != nil {
+		return romdb.Settings{}, fmt.Errorf("chip8: %w", err)
+	}
+	if err := c.LoadProgram(program); err != nil {
+		return romdb.Settings{}, fmt.Errorf("chip8: %w", err)
+	}
+	return romSettings, nil
+}
+
+// ExecuteFrame runs one frame of the program on c: up to tickrate
+// instructions, stopping early like cpu.CPU.Run, then the 60Hz timers.
+// If step is not nil, the instructions are executed one by one and step is
+// called before each of them, e.g. to sample a profile.
+func ExecuteFrame(c *cpu.CPU, tickrate int, step func(*cpu.CPU)) {
+	if step != nil {
+		for i := 0; i < tickrate && !c.Exited && !c.WaitingVBlank && c.Fault == nil; i++ {
+			step(c)
+			c.Step()
+		}
+	} else {
+		c.Run(tickrate)
+	}
+	c.UpdateTimers()
+}
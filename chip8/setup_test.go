@@ -0,0 +1,101 @@
+package chip8
+
+import (
+	"fmt"
+	"go-r8t/cpu"
+	"go-r8t/romdb"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	original := []byte{0x12, 0x00}            // 200: JP 200
+	patched := []byte{0x00, 0xE0, 0x12, 0x02} // 200: CLS; 202: JP 202
+	db, err := romdb.Parse([]byte(fmt.Sprintf(`[{
+		"title": "Loop",
+		"roms": {"%s": {
+			"platforms": ["superchip"],
+			"tickrate": 30,
+			"keys": {"a": 5},
+			"colors": {"pixels": ["#000000", "#FFFFFF"]}
+		}}
+	}]`, romdb.Hash(original))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := cpu.NewCPU()
+
+	tests := []struct {
+		name     string
+		file     string
+		opts     []Option
+		platform cpu.Platform
+		quirks   cpu.Quirks
+		tickrate int
+		named    bool
+	}{
+		{"unidentified", "loop.ch8", nil, cpu.PlatformCHIP8, defaults.Quirks, cpu.PlatformCHIP8.Tickrate(), false},
+		{"extension", "loop.xo8", nil, cpu.PlatformXOCHIP, cpu.PlatformXOCHIP.Quirks(), cpu.PlatformXOCHIP.Tickrate(), false},
+		{"database", "loop.ch8", []Option{WithDatabase(db)}, cpu.PlatformSCHIP, cpu.PlatformSCHIP.Quirks(), 30, true},
+		{
+			"platform", "loop.ch8", []Option{WithDatabase(db), WithPlatform(cpu.PlatformCHIP8)},
+			cpu.PlatformCHIP8, cpu.PlatformCHIP8.Quirks(), cpu.PlatformCHIP8.Tickrate(), true,
+		},
+		{
+			"quirks and tickrate", "loop.ch8", []Option{WithDatabase(db), WithQuirks(cpu.Quirks{Jump: true}), WithTickrate(99)},
+			cpu.PlatformSCHIP, cpu.Quirks{Jump: true}, 99, true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cpu.NewCPU()
+			settings, err := Setup(c, tt.file, original, patched, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Platform != tt.platform || c.Quirks != tt.quirks || settings.Tickrate != tt.tickrate {
+				t.Errorf("%s with quirks %+v at tickrate %d, want %s with %+v at %d",
+					c.Platform, c.Quirks, settings.Tickrate, tt.platform, tt.quirks, tt.tickrate)
+			}
+			if settings.Platform != c.Platform || settings.Quirks != c.Quirks {
+				t.Errorf("returned %s with %+v, but the CPU runs %s with %+v", settings.Platform, settings.Quirks, c.Platform, c.Quirks)
+			}
+			// The database's name, palette and keys apply whatever the platform
+			if named := settings.Name == "Loop"; named != tt.named {
+				t.Errorf("name %q, want the database's: %t", settings.Name, tt.named)
+			}
+			if tt.named && (len(settings.Palette) != 2 || settings.Keys["a"] != 5) {
+				t.Errorf("palette %v and keys %v, want the database's", settings.Palette, settings.Keys)
+			}
+			// The patched program is loaded with the platform's font
+			load := c.Platform.Descriptor().LoadAddress
+			if got := c.Memory[load : load+4]; string(got) != string(patched) {
+				t.Errorf("program at %03X is % X, want % X", load, got, patched)
+			}
+			if c.Font().Name != c.Platform.Font().Name {
+				t.Errorf("font %s, want %s", c.Font().Name, c.Platform.Font().Name)
+			}
+		})
+	}
+}
+
+func TestExecuteFrame(t *testing.T) {
+	// 200: 7001  ADD V0, 1; 202: 1200  JP 200
+	program := []byte{0x70, 0x01, 0x12, 0x00}
+	c := cpu.NewCPU()
+	if _, err := Setup(c, "", program, program); err != nil {
+		t.Fatal(err)
+	}
+	c.DelayTimer = 2
+
+	ExecuteFrame(c, 10, nil)
+	if c.V[0] != 5 || c.DelayTimer != 1 || c.Frame != 1 {
+		t.Errorf("V0 = %d, DT = %d, frame %d after a frame; want 5, 1, 1", c.V[0], c.DelayTimer, c.Frame)
+	}
+
+	// With a step function, it sees every instruction of the frame
+	var pcs []uint16
+	ExecuteFrame(c, 4, func(c *cpu.CPU) { pcs = append(pcs, c.PC) })
+	if fmt.Sprint(pcs) != "[512 514 512 514]" || c.V[0] != 7 || c.DelayTimer != 0 {
+		t.Errorf("stepped %v, V0 = %d, DT = %d; want [512 514 512 514], 7, 0", pcs, c.V[0], c.DelayTimer)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-r8t/analysis"
+	"go-r8t/cheats"
+	"go-r8t/cpu"
+	"go-r8t/inputlog"
+	"go-r8t/patch"
+	"go-r8t/romdb"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commands are the tools that run instead of the emulator, as go-r8t <command> [flags] args
+var commands = map[string]func(args []string) error{
+	"cfg":      runCFG,
+	"cheats":   runCheats,
+	"coverage": runCoverage,
+	"mkpatch":  runMkpatch,
+	"profile":  runProfile,
+}
+
+// runCommand runs the command named by the first argument.
+// It returns false if the arguments don't start with a command.
+func runCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		return false, nil
+	}
+	return true, command(args[1:])
+}
+
+// Writers of the cfg command's output formats
+var cfgWriters = map[string]func(*analysis.CFG, io.Writer) error{
+	"dot":  (*analysis.CFG).WriteDOT,
+	"json": (*analysis.CFG).WriteJSON,
+}
+
+// runCFG writes the control-flow graph of a ROM
+func runCFG(args []string) error {
+	flags := flag.NewFlagSet("cfg", flag.ExitOnError)
+	format := flags.String("format", "dot", "output format: dot or json")
+	output := flags.String("o", "", "output file (default: standard output)")
+	romdbPath := flags.String("romdb", romdb.DefaultOverridePath(), "local ROM database with additional or replacement entries")
+	flags.Func("platform", platformUsage, setPlatform)
+	path := parseROMArgs(flags, args)
+
+	// Check the format before creating the output file
+	write, ok := cfgWriters[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if err := LoadROMDatabase(*romdbPath); err != nil {
+		return err
+	}
+	chip8 := cpu.NewCPU()
+	if err := loadROM(chip8, path); err != nil {
+		return err
+	}
+	graph := analysis.Build(currentProgram, chip8.Platform)
+
+	if *output == "" {
+		return write(graph, os.Stdout)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := write(graph, f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// runCoverage runs a ROM without a frontend for a number of frames and writes its coverage
+func runCoverage(args []string) error {
+	flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	frames := flags.Int("frames", 600, "number of frames to run (60 per second)")
+	flags.StringVar(&coveragePrefix, "o", "", "path of the output files without extension (default: the ROM path)")
+	romdbPath := flags.String("romdb", romdb.DefaultOverridePath(), "local ROM database with additional or replacement entries")
+	inputPath := flags.String("input", "", "input log to replay, as written by -record")
+	flags.Func("memory", memoryPolicyUsage, setMemoryPolicy)
+	flags.Func("platform", platformUsage, setPlatform)
+	path := parseROMArgs(flags, args)
+	if coveragePrefix == "" {
+		coveragePrefix = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return runHeadless(path, *romdbPath, *inputPath, *frames)
+}
+
+// runProfile runs a ROM without a frontend for a number of frames and writes a pprof profile
+func runProfile(args []string) error {
+	flags := flag.NewFlagSet("profile", flag.ExitOnError)
+	frames := flags.Int("frames", 600, "number of frames to run (60 per second)")
+	flags.StringVar(&profilePath, "o", "", "output file (default: the ROM path with a .pprof extension)")
+	top := flags.Int("top", 10, "number of routines to list")
+	romdbPath := flags.String("romdb", romdb.DefaultOverridePath(), "local ROM database with additional or replacement entries")
+	inputPath := flags.String("input", "", "input log to replay, as written by -record")
+	flags.Func("memory", memoryPolicyUsage, setMemoryPolicy)
+	flags.Func("platform", platformUsage, setPlatform)
+	path := parseROMArgs(flags, args)
+	if profilePath == "" {
+		profilePath = strings.TrimSuffix(path, filepath.Ext(path)) + ".pprof"
+	}
+	if err := runHeadless(path, *romdbPath, *inputPath, *frames); err != nil {
+		return err
+	}
+
+	// activeProfiler is still set after the session ended
+	fmt.Printf("%-8s %12s %12s\n", "routine", "self", "total")
+	for i, r := range activeProfiler.Routines() {
+		if i == *top {
+			break
+		}
+		fmt.Printf("%-8s %12d %12d\n", fmt.Sprintf("%03X", r.Entry), r.Self, r.Total)
+	}
+	fmt.Printf("Profile written to %s\n", profilePath)
+	return nil
+}
+
+// runCheats runs a cheat console command for a ROM, e.g. to list or edit its saved cheats
+func runCheats(args []string) error {
+	flags := flag.NewFlagSet("cheats", flag.ExitOnError)
+	path := flags.String("file", cheats.DefaultConfigPath(), "cheat file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: go-r8t cheats [flags] rom [list | freeze TARGET VALUE [NAME] | unfreeze TARGET | toggle TARGET]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err := LoadCheats(*path); err != nil {
+		return err
+	}
+	if err := LoadROMDatabase(romdb.DefaultOverridePath()); err != nil {
+		return err
+	}
+	chip8 := cpu.NewCPU()
+	if err := loadROM(chip8, flags.Arg(0)); err != nil {
+		return err
+	}
+	command := "list"
+	if flags.NArg() > 1 {
+		command = strings.Join(flags.Args()[1:], " ")
+	}
+	fmt.Println(runCheatCommand(chip8, command))
+	return nil
+}
+
+// runMkpatch creates a patch that turns one ROM into another
+func runMkpatch(args []string) error {
+	flags := flag.NewFlagSet("mkpatch", flag.ExitOnError)
+	format := flags.String("format", "", "patch format: bps or ips (default: from the output file's extension, or bps)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: go-r8t mkpatch [flags] original modified patch")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 3 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	output := flags.Arg(2)
+	if *format == "" {
+		*format = "bps"
+		if strings.EqualFold(filepath.Ext(output), ".ips") {
+			*format = "ips"
+		}
+	}
+
+	original, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	modified, err := os.ReadFile(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	data, err := patch.Create(*format, original, modified)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, data, 0o644)
+}
+
+// parseROMArgs parses the flags of a command that takes a single ROM and returns the ROM's path
+func parseROMArgs(flags *flag.FlagSet, args []string) string {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-r8t %s [flags] rom\n", flags.Name())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return flags.Arg(0)
+}
+
+// runHeadless runs a ROM without a frontend, recording the session like a played game.
+// The keypad is driven by the input log at inputPath, if given. A memory fault
+// ends the run; the session is still saved and the fault returned.
+func runHeadless(path, romdbPath, inputPath string, frames int) error {
+	if err := LoadROMDatabase(romdbPath); err != nil {
+		return err
+	}
+	if inputPath != "" {
+		player, err := inputlog.Load(inputPath)
+		if err != nil {
+			return err
+		}
+		inputReplay = player
+	}
+	chip8 := cpu.NewCPU()
+	if err := loadROM(chip8, path); err != nil {
+		return err
+	}
+	startSession(chip8)
+	for i := 0; i < frames && !chip8.Exited && chip8.Fault == nil; i++ {
+		runFrame(chip8)
+	}
+	if err := endSession(chip8); err != nil {
+		return err
+	}
+	if chip8.Fault != nil {
+		return fmt.Errorf("%s: %w", path, chip8.Fault)
+	}
+	return nil
+}
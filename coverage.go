@@ -0,0 +1,64 @@
+package main
+
+import (
+	"go-r8t/analysis"
+	"go-r8t/cpu"
+	"go-r8t/profiler"
+	"os"
+)
+
+// Size of each address in the coverage heatmap, in pixels
+const heatmapScale = 8
+
+// Files written at the end of each session, empty to disable recording
+var (
+	coveragePrefix string // Path prefix of the coverage listing and heatmap
+	profilePath    string // Path of the pprof profile
+)
+
+// Profiler of the current session, if profiling
+var activeProfiler *profiler.Profiler
+
+// writeCoverage writes the annotated listing (prefix.lst) and the memory heatmap (prefix.png) of a session
+// Parameters:
+//   - prefix: Path of the output files without extension
+//   - program: The ROM that was run
+//   - platform: The platform the ROM ran on
+//   - coverage: The accesses recorded during the session
+func writeCoverage(prefix string, program []byte, platform cpu.Platform, coverage *cpu.Coverage) error {
+	listing, err := os.Create(prefix + ".lst")
+	if err != nil {
+		return err
+	}
+	defer listing.Close()
+	if err := analysis.WriteListing(listing, program, platform, coverage); err != nil {
+		return err
+	}
+
+	heatmap, err := os.Create(prefix + ".png")
+	if err != nil {
+		return err
+	}
+	defer heatmap.Close()
+	if err := analysis.WriteHeatmap(heatmap, coverage, platform, len(program), heatmapScale); err != nil {
+		return err
+	}
+
+	if err := listing.Close(); err != nil {
+		return err
+	}
+	return heatmap.Close()
+}
+
+// writeProfile writes a session's profile in pprof format
+func writeProfile(path string, p *profiler.Profiler) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := p.WritePprof(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -20,62 +20,79 @@ func (cpu *CPU) PerformArithmeticOperation(x, y, sel uint16) {
 		// Set Vx = Vx OR Vy
 		// Performs a bitwise OR on the values of Vx and Vy, then stores the result in Vx.
 		cpu.V[x] |= cpu.V[y]
+		cpu.resetFlagForLogic()
 	case 0x2:
 		// 8XY2 - AND Vx, Vy
 		// Set Vx = Vx AND Vy
 		// Performs a bitwise AND on the values of Vx and Vy, then stores the result in Vx.
 		cpu.V[x] &= cpu.V[y]
+		cpu.resetFlagForLogic()
 	case 0x3:
 		// 8XY3 - XOR Vx, Vy
 		// Set Vx = Vx XOR Vy
 		// Performs a bitwise exclusive OR on the values of Vx and Vy, then stores the result in Vx.
 		cpu.V[x] ^= cpu.V[y]
+		cpu.resetFlagForLogic()
 	case 0x4:
 		// 8XY4 - ADD Vx, Vy
 		// Set Vx = Vx + Vy, set VF = carry
 		// The values of Vx and Vy are added together. If the result is greater than 8 bits (i.e., > 255),
 		// VF is set to 1, otherwise 0. Only the lowest 8 bits of the result are kept, and stored in Vx.
 		result := uint16(cpu.V[x]) + uint16(cpu.V[y])
-		if result > 0xFF {
-			cpu.V[0xF] = 1
-		} else {
-			cpu.V[0xF] = 0
-		}
-		cpu.V[x] = byte(result)
+		cpu.setWithFlag(x, byte(result), result > 0xFF)
 	case 0x5:
 		// 8XY5 - SUB Vx, Vy
 		// Set Vx = Vx - Vy, set VF = NOT borrow
-		// If Vx > Vy, then VF is set to 1, otherwise 0. Then Vy is subtracted from Vx, and the results stored in Vx.
-		if uint16(cpu.V[x]) > uint16(cpu.V[y]) {
-			cpu.V[0xF] = 1
-		} else {
-			cpu.V[0xF] = 0
-		}
-		cpu.V[x] -= cpu.V[y]
+		// If Vx >= Vy, then VF is set to 1, otherwise 0. Then Vy is subtracted from Vx, and the results stored in Vx.
+		cpu.setWithFlag(x, cpu.V[x]-cpu.V[y], cpu.V[x] >= cpu.V[y])
 	case 0x6:
 		// 8XY6 - SHR Vx {, Vy}
 		// Set Vx = Vx SHR 1
 		// If the least-significant bit of Vx is 1, then VF is set to 1, otherwise 0. Then Vx is divided by 2.
-		cpu.V[0xF] = cpu.V[x] & 0x1
-		cpu.V[x] >>= 1
+		// Without the shift quirk, Vy is shifted instead of Vx.
+		source := cpu.shiftSource(x, y)
+		cpu.setWithFlag(x, source>>1, source&0x1 == 1)
 	case 0x7:
 		// 8XY7 - SUBN Vx, Vy
 		// Set Vx = Vy - Vx, set VF = NOT borrow
-		// If Vy > Vx, then VF is set to 1, otherwise 0. Then Vx is subtracted from Vy, and the results stored in Vx.
-		if uint16(cpu.V[y]) > uint16(cpu.V[x]) {
-			cpu.V[0xF] = 1
-		} else {
-			cpu.V[0xF] = 0
-		}
-		cpu.V[x] = cpu.V[y] - cpu.V[x]
+		// If Vy >= Vx, then VF is set to 1, otherwise 0. Then Vx is subtracted from Vy, and the results stored in Vx.
+		cpu.setWithFlag(x, cpu.V[y]-cpu.V[x], cpu.V[y] >= cpu.V[x])
 	case 0xE:
 		// 8XYE - SHL Vx {, Vy}
 		// Set Vx = Vx SHL 1
 		// If the most-significant bit of Vx is 1, then VF is set to 1, otherwise to 0. Then Vx is multiplied by 2.
-		cpu.V[0xF] = (cpu.V[x] >> 7) & 0x1
-		cpu.V[x] <<= 1
+		// Without the shift quirk, Vy is shifted instead of Vx.
+		source := cpu.shiftSource(x, y)
+		cpu.setWithFlag(x, source<<1, source>>7 == 1)
 	default:
 		// Unknown arithmetic operation
 		fmt.Printf("Unknown arithmetic operation: 0x%X\n", sel)
 	}
 }
+
+// resetFlagForLogic resets VF after 8XY1/8XY2/8XY3 when the logic quirk is enabled.
+func (cpu *CPU) resetFlagForLogic() {
+	if cpu.Quirks.Logic {
+		cpu.V[0xF] = 0
+	}
+}
+
+// shiftSource returns the value 8XY6/8XYE shift: Vx with the shift quirk, Vy otherwise.
+func (cpu *CPU) shiftSource(x, y uint16) byte {
+	if cpu.Quirks.Shift {
+		return cpu.V[x]
+	}
+	return cpu.V[y]
+}
+
+// setWithFlag stores the result of an arithmetic operation in Vx and its flag in VF.
+// Both are computed from the operands before either is written, and VF is
+// written last, so the flag wins when Vx is VF (e.g. 8FF4).
+func (cpu *CPU) setWithFlag(x uint16, result byte, flag bool) {
+	cpu.V[x] = result
+	if flag {
+		cpu.V[0xF] = 1
+	} else {
+		cpu.V[0xF] = 0
+	}
+}
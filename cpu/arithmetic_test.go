@@ -0,0 +1,151 @@
+package cpu
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Operations of the 8XYN instructions, by N
+var arithmeticOperations = []uint16{0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0xE}
+
+// expectedArithmetic computes the registers after 8XYN independently of the CPU:
+// both operands are read first, the result is written to Vx and the flag to VF last.
+func expectedArithmetic(v [16]byte, x, y, n uint16, quirks Quirks) [16]byte {
+	vx, vy := v[x], v[y]
+	shifted := vy
+	if quirks.Shift {
+		shifted = vx
+	}
+	flag := func(set bool) {
+		v[0xF] = 0
+		if set {
+			v[0xF] = 1
+		}
+	}
+	switch n {
+	case 0x0:
+		v[x] = vy
+	case 0x1, 0x2, 0x3:
+		switch n {
+		case 0x1:
+			v[x] = vx | vy
+		case 0x2:
+			v[x] = vx & vy
+		case 0x3:
+			v[x] = vx ^ vy
+		}
+		if quirks.Logic {
+			v[0xF] = 0
+		}
+	case 0x4:
+		v[x] = vx + vy
+		flag(int(vx)+int(vy) > 0xFF)
+	case 0x5:
+		v[x] = vx - vy
+		flag(vx >= vy)
+	case 0x6:
+		v[x] = shifted >> 1
+		flag(shifted&1 == 1)
+	case 0x7:
+		v[x] = vy - vx
+		flag(vy >= vx)
+	case 0xE:
+		v[x] = shifted << 1
+		flag(shifted&0x80 != 0)
+	}
+	return v
+}
+
+func TestArithmeticFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode uint16
+		v      map[int]byte // Registers before the instruction; the others are 0
+		quirks Quirks
+		vx     byte // Expected Vx, unless X is F
+		vf     byte // Expected VF
+	}{
+		{"8XY4 carry", 0x8124, map[int]byte{1: 0xFF, 2: 0x02}, Quirks{}, 0x01, 1},
+		{"8XY4 no carry", 0x8124, map[int]byte{1: 0xFE, 2: 0x01}, Quirks{}, 0xFF, 0},
+		{"8FY4 flag wins over the sum", 0x8F14, map[int]byte{0xF: 0xFF, 1: 0x01}, Quirks{}, 0, 1},
+		{"8FY4 no carry", 0x8F14, map[int]byte{0xF: 0x10, 1: 0x01}, Quirks{}, 0, 0},
+		{"8XF4 VF as operand", 0x81F4, map[int]byte{1: 0x80, 0xF: 0x80}, Quirks{}, 0x00, 1},
+		{"8XY5 equal operands", 0x8125, map[int]byte{1: 0x42, 2: 0x42}, Quirks{}, 0x00, 1},
+		{"8XF5 equal operands", 0x81F5, map[int]byte{1: 0x42, 0xF: 0x42}, Quirks{}, 0x00, 1},
+		{"8XY5 borrow", 0x8125, map[int]byte{1: 0x01, 2: 0x02}, Quirks{}, 0xFF, 0},
+		{"8XX5 same register", 0x8115, map[int]byte{1: 0x42}, Quirks{}, 0x00, 1},
+		{"8XY7 equal operands", 0x8127, map[int]byte{1: 0x42, 2: 0x42}, Quirks{}, 0x00, 1},
+		{"8XY7 borrow", 0x8127, map[int]byte{1: 0x02, 2: 0x01}, Quirks{}, 0xFF, 0},
+		{"8XY6 shifts Vy", 0x8126, map[int]byte{1: 0x02, 2: 0x03}, Quirks{}, 0x01, 1},
+		{"8XY6 shift quirk shifts Vx", 0x8126, map[int]byte{1: 0x02, 2: 0x03}, Quirks{Shift: true}, 0x01, 0},
+		{"8XYE shifts Vy", 0x812E, map[int]byte{1: 0x01, 2: 0x81}, Quirks{}, 0x02, 1},
+		{"8XYE shift quirk shifts Vx", 0x812E, map[int]byte{1: 0x01, 2: 0x81}, Quirks{Shift: true}, 0x02, 0},
+		{"8FF6 flag wins over the result", 0x8FF6, map[int]byte{0xF: 0x03}, Quirks{Shift: true}, 0, 1},
+		{"8XY1 keeps VF", 0x8121, map[int]byte{1: 0x0F, 2: 0xF0, 0xF: 0x07}, Quirks{}, 0xFF, 0x07},
+		{"8XY1 logic quirk resets VF", 0x8121, map[int]byte{1: 0x0F, 2: 0xF0, 0xF: 0x07}, Quirks{Logic: true}, 0xFF, 0},
+		{"8XY2 logic quirk resets VF", 0x8122, map[int]byte{1: 0x0F, 2: 0xFF, 0xF: 0x07}, Quirks{Logic: true}, 0x0F, 0},
+		{"8XY3 logic quirk resets VF", 0x8123, map[int]byte{1: 0x0F, 2: 0xFF, 0xF: 0x07}, Quirks{Logic: true}, 0xF0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.Quirks = tt.quirks
+			for i, value := range tt.v {
+				cpu.V[i] = value
+			}
+			cpu.ExecuteInstruction(tt.opcode)
+			x := (tt.opcode >> 8) & 0xF
+			if x != 0xF && cpu.V[x] != tt.vx {
+				t.Errorf("V%X = %02X, want %02X", x, cpu.V[x], tt.vx)
+			}
+			if cpu.V[0xF] != tt.vf {
+				t.Errorf("VF = %02X, want %02X", cpu.V[0xF], tt.vf)
+			}
+		})
+	}
+}
+
+// TestArithmeticAllRegisters runs every 8XYN instruction for every pair of
+// registers, including VF as either operand and X equal to Y, with and
+// without the shift and logic quirks.
+func TestArithmeticAllRegisters(t *testing.T) {
+	// Register values: edge cases, then random ones
+	var values [][16]byte
+	for _, b := range []byte{0x00, 0x01, 0x7F, 0x80, 0xFF} {
+		var v [16]byte
+		for i := range v {
+			v[i] = b
+		}
+		values = append(values, v)
+	}
+	r := rand.New(rand.NewSource(1))
+	for range 8 {
+		var v [16]byte
+		for i := range v {
+			v[i] = byte(r.Intn(256))
+		}
+		values = append(values, v)
+	}
+
+	for _, quirks := range []Quirks{{}, {Shift: true}, {Logic: true}, {Shift: true, Logic: true}} {
+		for _, n := range arithmeticOperations {
+			t.Run(fmt.Sprintf("8XY%X/shift=%t/logic=%t", n, quirks.Shift, quirks.Logic), func(t *testing.T) {
+				for x := uint16(0); x < 16; x++ {
+					for y := uint16(0); y < 16; y++ {
+						for _, v := range values {
+							cpu := NewCPU()
+							cpu.Quirks = quirks
+							cpu.V = v
+							opcode := 0x8000 | x<<8 | y<<4 | n
+							cpu.ExecuteInstruction(opcode)
+							if want := expectedArithmetic(v, x, y, n, quirks); cpu.V != want {
+								t.Fatalf("%04X with V = %X: got V = %X, want %X", opcode, v, cpu.V, want)
+							}
+						}
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package cpu
+
+// Bus is the memory the CPU fetches instructions from and operates on.
+// Set CPU.Bus with SetBus to put hooks between the CPU and its memory, or to
+// give it memory of a different size. A nil Bus uses CPU.Memory directly.
+type Bus interface {
+	Read8(address uint16) byte
+	Write8(address uint16, value byte)
+}
+
+// SizedBus is a Bus that knows its size. The CPU's memory policy then applies
+// at the end of the bus instead of at the end of Memory.
+type SizedBus interface {
+	Bus
+	Size() int // Bytes of memory, or 0 if unknown
+}
+
+// busSize returns the size of a bus, or 0 if it doesn't know it.
+func busSize(bus Bus) int {
+	if sized, ok := bus.(SizedBus); ok {
+		return sized.Size()
+	}
+	return 0
+}
+
+// interpreterEnd is the first address after the interpreter area, where
+// programs are loaded
+const interpreterEnd = 0x200
+
+// RAM is plain memory of any size up to 64KB. Addresses wrap around at its size.
+type RAM []byte
+
+// NewRAM creates size bytes of zeroed memory.
+func NewRAM(size int) RAM {
+	return make(RAM, size)
+}
+
+// Read8 returns the byte at address.
+func (m RAM) Read8(address uint16) byte {
+	return m[int(address)%len(m)]
+}
+
+// Write8 stores a byte at address.
+func (m RAM) Write8(address uint16, value byte) {
+	m[int(address)%len(m)] = value
+}
+
+// Size returns the size of the memory.
+func (m RAM) Size() int {
+	return len(m)
+}
+
+// ReadHook is called after a byte has been read from a HookedBus.
+type ReadHook func(address uint16, value byte)
+
+// WriteHook is called before a byte is written to a HookedBus.
+// Returning false drops the write.
+type WriteHook func(address uint16, value byte) bool
+
+// HookedBus calls hooks on every access to another bus, e.g. for watchpoints
+// or to write-protect a region.
+type HookedBus struct {
+	bus    Bus
+	reads  []ReadHook
+	writes []WriteHook
+}
+
+// NewHookedBus wraps a bus without any hooks.
+func NewHookedBus(bus Bus) *HookedBus {
+	return &HookedBus{bus: bus}
+}
+
+// OnRead adds a hook that is called after every read.
+func (b *HookedBus) OnRead(hook ReadHook) {
+	b.reads = append(b.reads, hook)
+}
+
+// OnWrite adds a hook that is called before every write.
+// Hooks run in the order they were added, until one drops the write.
+func (b *HookedBus) OnWrite(hook WriteHook) {
+	b.writes = append(b.writes, hook)
+}
+
+// Read8 reads a byte from the wrapped bus and calls the read hooks.
+func (b *HookedBus) Read8(address uint16) byte {
+	value := b.bus.Read8(address)
+	for _, hook := range b.reads {
+		hook(address, value)
+	}
+	return value
+}
+
+// Write8 calls the write hooks and writes the byte to the wrapped bus unless
+// a hook dropped it.
+func (b *HookedBus) Write8(address uint16, value byte) {
+	for _, hook := range b.writes {
+		if !hook(address, value) {
+			return
+		}
+	}
+	b.bus.Write8(address, value)
+}
+
+// Size returns the size of the wrapped bus, or 0 if it doesn't know it.
+func (b *HookedBus) Size() int {
+	return busSize(b.bus)
+}
+
+// ProtectInterpreter is a WriteHook that drops writes to the interpreter
+// area (0x000-0x1FF), which holds the fonts.
+func ProtectInterpreter(address uint16, value byte) bool {
+	return address >= interpreterEnd
+}
+
+// SetBus makes the CPU access memory through bus, or directly through Memory
+// when bus is nil. The interpreter area, which holds the fonts, is copied onto
+// the new bus, so set it before loading a program and before adding hooks
+// that protect the interpreter area.
+func (cpu *CPU) SetBus(bus Bus) {
+	cpu.Bus = bus
+	cpu.decoded = nil
+	if bus == nil {
+		return
+	}
+	for address := uint16(0); address < interpreterEnd; address++ {
+		bus.Write8(address, cpu.Memory[address])
+	}
+}
+
+// Read8 reads a byte of the CPU's memory.
+func (cpu *CPU) Read8(address uint16) byte {
+	if cpu.Bus != nil {
+		return cpu.Bus.Read8(address)
+	}
+	return cpu.Memory[address]
+}
+
+// Write8 writes a byte of the CPU's memory.
+// Writing to Memory directly would leave stale decoded instructions behind.
+func (cpu *CPU) Write8(address uint16, value byte) {
+	if cpu.Bus != nil {
+		cpu.Bus.Write8(address, value)
+		return
+	}
+	cpu.Memory[address] = value
+	cpu.invalidate(address)
+}
@@ -0,0 +1,99 @@
+package cpu
+
+import "testing"
+
+func TestBusMemoryPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy MemoryPolicy
+		check  func(t *testing.T, cpu *CPU, ram RAM)
+	}{
+		{"wrap", MemoryWrap, func(t *testing.T, cpu *CPU, ram RAM) {
+			if ram[0x3FF] != 0x11 || ram[0] != 0x22 {
+				t.Errorf("RAM[3FF], RAM[0] = %02X, %02X, want 11, 22", ram[0x3FF], ram[0])
+			}
+		}},
+		{"clamp", MemoryClamp, func(t *testing.T, cpu *CPU, ram RAM) {
+			if ram[0x3FF] != 0x22 {
+				t.Errorf("RAM[3FF] = %02X, want 22", ram[0x3FF])
+			}
+		}},
+		{"fault", MemoryFault, func(t *testing.T, cpu *CPU, ram RAM) {
+			if cpu.Fault == nil || cpu.Fault.Address != 0x400 {
+				t.Fatalf("Fault = %v, want a fault at 400", cpu.Fault)
+			}
+			if ram[0x3FF] != 0 {
+				t.Errorf("RAM[3FF] = %02X, want 00: the faulting FX55 must not write", ram[0x3FF])
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu := NewCPU()
+			ram := NewRAM(1024)
+			cpu.SetBus(ram)
+			cpu.MemoryPolicy = tt.policy
+			program := assemble(
+				0x6011, // LD V0, 11
+				0x6122, // LD V1, 22
+				0xA3FF, // LD I, 3FF
+				0xF155, // LD [I], V1
+			)
+			if err := cpu.LoadProgram(program); err != nil {
+				t.Fatal(err)
+			}
+			for range 4 {
+				cpu.Step()
+			}
+			tt.check(t, cpu, ram)
+		})
+	}
+}
+
+func TestHookedBusSize(t *testing.T) {
+	if size := NewHookedBus(NewRAM(2048)).Size(); size != 2048 {
+		t.Errorf("size of hooked RAM = %d, want 2048", size)
+	}
+	cpu := NewCPU()
+	cpu.SetBus(NewHookedBus(NewRAM(2048)))
+	if size := cpu.memorySize(); size != 2048 {
+		t.Errorf("memory size with a 2KB bus = %d, want 2048", size)
+	}
+	if err := cpu.LoadProgram(make([]byte, 2048-0x200+1)); err == nil {
+		t.Error("loading a program larger than the bus succeeded")
+	}
+}
+
+// BenchmarkBus compares the direct access to Memory with the buses.
+func BenchmarkBus(b *testing.B) {
+	const n = 1000
+	buses := []struct {
+		name string
+		bus  func() Bus
+	}{
+		{"Memory", func() Bus { return nil }},
+		{"RAM", func() Bus { return NewRAM(4096) }},
+		{"HookedBus", func() Bus {
+			bus := NewHookedBus(NewRAM(4096))
+			bus.OnRead(func(uint16, byte) {})
+			bus.OnWrite(ProtectInterpreter)
+			return bus
+		}},
+	}
+	for _, bb := range buses {
+		b.Run(bb.name, func(b *testing.B) {
+			cpu := NewCPU()
+			if bus := bb.bus(); bus != nil {
+				cpu.SetBus(bus)
+			}
+			if err := cpu.LoadProgram(assemble(benchmarkLoop...)); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for range b.N {
+				cpu.Run(n)
+			}
+			reportInstructionRate(b, b.N*n)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package cpu
+
+// Coverage counts how often each memory address was executed, read and written.
+// Attach one to CPU.Coverage to record a session; a nil Coverage records nothing.
+type Coverage struct {
+	Executed [4096]uint64 // Instructions fetched from the address
+	Read     [4096]uint64 // Sprite data (DXYN) and register loads (FX65)
+	Written  [4096]uint64 // BCD stores (FX33) and register stores (FX55)
+}
+
+// NewCoverage creates an empty coverage record.
+func NewCoverage() *Coverage {
+	return &Coverage{}
+}
+
+// Reset clears all counts.
+func (c *Coverage) Reset() {
+	*c = Coverage{}
+}
+
+// recordExecute records the instruction fetch at address
+func (cpu *CPU) recordExecute(address uint16) {
+	if cpu.Coverage != nil {
+		cpu.Coverage.Executed[address&0xFFF]++
+	}
+}
+
+// recordRead records a data read of n bytes starting at address
+func (cpu *CPU) recordRead(address, n uint16) {
+	if cpu.Coverage != nil {
+		for i := uint16(0); i < n; i++ {
+			cpu.Coverage.Read[(address+i)&0xFFF]++
+		}
+	}
+}
+
+// recordWrite records a write of n bytes starting at address
+func (cpu *CPU) recordWrite(address, n uint16) {
+	if cpu.Coverage != nil {
+		for i := uint16(0); i < n; i++ {
+			cpu.Coverage.Written[(address+i)&0xFFF]++
+		}
+	}
+}
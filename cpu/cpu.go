@@ -1,69 +1,120 @@
 package cpu
 
-import (
-	"fmt"
-	"math/rand"
-)
-
-// Font data for hexadecimal digits 0-F
-var fontSet = []byte{
-	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
-	0x20, 0x60, 0x20, 0x20, 0x70, // 1
-	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
-	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
-	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
-	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
-	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
-	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
-	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
-	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
-	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
-	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
-	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
-	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
-	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
-	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
-}
+import "math/rand/v2"
 
 // CPU represents the CHIP-8 virtual machine state.
 // It contains all the registers, memory, and state needed to execute CHIP-8 programs.
 type CPU struct {
-	PC            uint16        // Program Counter - points to the current instruction in memory
-	Memory        [4096]byte    // 4KB of memory (0x000-0x1FF: System memory, 0x200-0xFFF: Program memory)
-	V             [16]byte      // 16 general-purpose registers (V0-VF)
-	Stack         [16]uint16    // Stack for subroutine calls (16 levels deep)
-	I             uint16        // Index register - used for memory operations and sprite drawing
-	SP            uint8         // Stack Pointer - points to the current stack level
-	DelayTimer    uint8         // Delay timer - decrements at 60Hz when non-zero
-	SoundTimer    uint8         // Sound timer - decrements at 60Hz when non-zero, beeps when non-zero
-	Keys          [16]bool      // State of the 16-key hexadecimal keypad (0x0-0xF)
-	Display       [64 * 32]byte // Display memory (64x32 pixels, 1 bit per pixel)
-	CurrentOpcode uint16        // The current instruction being executed
+	PC            uint16         // Program Counter - points to the current instruction in memory
+	Memory        [4096]byte     // 4KB of memory (0x000-0x1FF: System memory, 0x200-0xFFF: Program memory, on most platforms); write it with Write8 once running
+	V             [16]byte       // 16 general-purpose registers (V0-VF)
+	Stack         [16]uint16     // Stack for subroutine calls (16 levels deep)
+	I             uint16         // Index register - used for memory operations and sprite drawing
+	SP            uint8          // Stack Pointer - points to the current stack level
+	DelayTimer    uint8          // Delay timer - decrements at 60Hz when non-zero
+	SoundTimer    uint8          // Sound timer - decrements at 60Hz when non-zero, beeps when non-zero
+	Keys          [16]bool       // State of the 16-key hexadecimal keypad (0x0-0xF)
+	Display       [128 * 64]byte // Display memory (64x32 or 128x64 pixels in hires mode, 1 byte per pixel; see DisplaySize)
+	Hires         bool           // SCHIP high resolution (128x64) display mode
+	Exited        bool           // Set by the SCHIP 00FD instruction; no further instructions are executed
+	WaitingVBlank bool           // Set by DXYN with the vblank quirk; no instructions are executed until UpdateTimers
+	KeyWait       KeyWait        // Progress of an FX0A instruction waiting for a key
+	CurrentOpcode uint16         // The current instruction being executed
+	Platform      Platform       // The CHIP-8 variant being emulated
+	Quirks        Quirks         // Behaviours that differ between interpreters
+	Flags         [16]byte       // HP48 RPL user flags, saved and loaded by SCHIP FX75/FX85
+	Coverage      *Coverage      // Records memory accesses when set
+	Bus           Bus            // Memory accessed instead of Memory when set; see SetBus
+	Frame         uint64         // Number of frames run, counted by UpdateTimers
+	MemoryPolicy  MemoryPolicy   // What DXYN, FX33, FX55 and FX65 do past the end of memory
+	Fault         *Fault         // Set by an access out of bounds with MemoryFault; no further instructions are executed
+	FontAddress   uint16         // Address of the small font, followed by the big font; see SetFont
+
+	decoded *decodeCache // Decoded instructions of Memory, allocated by the first Step
+	dirty   []Rect       // Display regions changed since the last TakeDirty
+	vblank  bool         // The vertical blank a waiting DXYN waited for has happened
+	font    Font         // Font loaded by SetFont
+	random  rand.PCG     // Generator of CXNN's random bytes; see SeedRandom
+}
+
+// KeyWait is the state of FX0A, which waits for a key to be pressed and released.
+type KeyWait struct {
+	Pressed bool  // Key has been pressed; FX0A waits for its release
+	Key     uint8 // The pressed key
 }
 
 // NewCPU creates and returns a new CPU instance.
+// It starts with the quirks this emulator has always used; call SetPlatform
+// to emulate a specific platform's interpreter.
 func NewCPU() *CPU {
 	cpu := &CPU{
-		PC: 0x200, // Program counter starts at 0x200
+		PC:     0x200, // Program counter starts at 0x200
+		Quirks: Quirks{Shift: true},
 	}
 	cpu.ClearScreen() // Clear the display on initialization
+	cpu.SeedRandom(rand.Uint64())
 
-	// Load font data into memory at address 0
-	for i, fontByte := range fontSet {
-		cpu.Memory[i] = fontByte
-	}
+	// Load the platform's font into the interpreter area
+	cpu.SetFont(cpu.Platform.Font(), cpu.Platform.Descriptor().FontAddress)
 
 	return cpu
 }
 
-// LoadProgram loads a CHIP-8 program into memory starting at address 0x200.
-// This is the standard starting address for CHIP-8 programs.
-// Parameters:
-//   - program: The byte slice containing the CHIP-8 program to load
-func (cpu *CPU) LoadProgram(program []byte) {
-	for i := range program {
-		cpu.Memory[0x200+i] = program[i]
+// SeedRandom seeds the generator of the random bytes of CXNN, so that a
+// program runs the same way every time it is run with the same seed and input.
+func (cpu *CPU) SeedRandom(seed uint64) {
+	cpu.random.Seed(seed, 0)
+}
+
+// Step fetches the instruction at PC and executes it.
+// Instructions are decoded once and then run from a cache. A Bus is still
+// read on every fetch, so its hooks see every instruction.
+func (cpu *CPU) Step() {
+	if cpu.Exited || cpu.WaitingVBlank || cpu.Fault != nil {
+		return
+	}
+	cpu.recordExecute(cpu.PC)
+	var in *instruction
+	if d := cpu.decoded; d != nil && cpu.Bus == nil {
+		in = &d[cpu.PC&0xFFF]
 	}
+	if in == nil || in.exec == nil {
+		in = cpu.fetch()
+	}
+	cpu.CurrentOpcode = in.opcode
+	in.exec(cpu, in)
+}
+
+// Run executes up to n instructions, stopping early if the program exits,
+// faults or waits for the vertical blank.
+// It returns the number of instructions executed. It is faster than calling
+// Step n times, unless coverage is recorded or a Bus is set.
+func (cpu *CPU) Run(n int) int {
+	if cpu.Bus != nil || cpu.Coverage != nil {
+		for i := 0; i < n; i++ {
+			if cpu.Exited || cpu.WaitingVBlank || cpu.Fault != nil {
+				return i
+			}
+			cpu.Step()
+		}
+		return n
+	}
+	if cpu.decoded == nil {
+		cpu.decoded = new(decodeCache)
+	}
+	d := cpu.decoded
+	for i := 0; i < n; i++ {
+		if cpu.Exited || cpu.WaitingVBlank || cpu.Fault != nil {
+			return i
+		}
+		in := &d[cpu.PC&0xFFF]
+		if in.exec == nil {
+			in = cpu.fetch()
+		}
+		cpu.CurrentOpcode = in.opcode
+		in.exec(cpu, in)
+	}
+	return n
 }
 
 // ExecuteInstruction decodes and executes a single CHIP-8 instruction.
@@ -73,138 +124,8 @@ func (cpu *CPU) LoadProgram(program []byte) {
 //   - instruction: The 16-bit instruction to execute
 func (cpu *CPU) ExecuteInstruction(instruction uint16) {
 	cpu.CurrentOpcode = instruction
-	switch instruction & 0xF000 {
-	case 0x0000:
-		if instruction == 0x00E0 {
-			// 00E0 - CLS
-			// Clear the display
-			cpu.ClearScreen()
-			cpu.PC += 2
-		} else if instruction == 0x00EE {
-			// 00EE - RET
-			// Return from a subroutine
-			cpu.ReturnFromSubroutine()
-		}
-	case 0x1000:
-		// 1NNN - JP addr
-		// Jump to location NNN
-		cpu.PC = instruction & 0x0FFF
-	case 0x2000:
-		// 2NNN - CALL addr
-		// Call subroutine at NNN
-		cpu.Stack[cpu.SP] = cpu.PC
-		cpu.SP++
-		cpu.PC = instruction & 0x0FFF
-	case 0x3000:
-		// 3XNN - SE Vx, byte
-		// Skip next instruction if Vx = NN
-		x := (instruction & 0x0F00) >> 8
-		nn := byte(instruction & 0x00FF)
-		if cpu.V[x] == nn {
-			cpu.PC += 4
-		} else {
-			cpu.PC += 2
-		}
-	case 0x4000:
-		// 4XNN - SNE Vx, byte
-		// Skip next instruction if Vx != NN
-		x := (instruction & 0x0F00) >> 8
-		nn := byte(instruction & 0x00FF)
-		if cpu.V[x] != nn {
-			cpu.PC += 4
-		} else {
-			cpu.PC += 2
-		}
-	case 0x5000:
-		// 5XY0 - SE Vx, Vy
-		// Skip next instruction if Vx = Vy
-		x := (instruction & 0x0F00) >> 8
-		y := (instruction & 0x00F0) >> 4
-		if cpu.V[x] == cpu.V[y] {
-			cpu.PC += 4
-		} else {
-			cpu.PC += 2
-		}
-	case 0x6000:
-		// 6XNN - LD Vx, byte
-		// Set Vx = NN
-		x := (instruction & 0x0F00) >> 8
-		cpu.V[x] = byte(instruction & 0x00FF)
-		cpu.PC += 2
-	case 0x7000:
-		// 7XNN - ADD Vx, byte
-		// Set Vx = Vx + NN
-		x := (instruction & 0x0F00) >> 8
-		cpu.V[x] += byte(instruction & 0x00FF)
-		cpu.PC += 2
-	case 0x8000:
-		// 8XYN - Various arithmetic and logical operations
-		x := (instruction & 0x0F00) >> 8
-		y := (instruction & 0x00F0) >> 4
-		sel := instruction & 0x000F
-		cpu.PerformArithmeticOperation(x, y, sel)
-		cpu.PC += 2
-	case 0x9000:
-		// 9XY0 - SNE Vx, Vy
-		// Skip next instruction if Vx != Vy
-		x := (instruction & 0x0F00) >> 8
-		y := (instruction & 0x00F0) >> 4
-		if cpu.V[x] != cpu.V[y] {
-			cpu.PC += 4
-		} else {
-			cpu.PC += 2
-		}
-	case 0xA000:
-		// ANNN - LD I, addr
-		// Set I = NNN
-		cpu.I = instruction & 0x0FFF
-		cpu.PC += 2
-	case 0xB000:
-		// BNNN - JP V0, addr
-		// Jump to location NNN + V0
-		cpu.PC = (instruction & 0x0FFF) + uint16(cpu.V[0])
-	case 0xC000:
-		// CXNN - RND Vx, byte
-		// Set Vx = random byte AND NN
-		x := (instruction & 0x0F00) >> 8
-		nn := byte(instruction & 0x00FF)
-		random := byte(rand.Intn(256))
-		cpu.V[x] = random & nn
-		cpu.PC += 2
-	case 0xD000:
-		// DXYN - DRW Vx, Vy, nibble
-		// Display N-byte sprite starting at memory location I at (Vx, Vy)
-		x := (instruction & 0x0F00) >> 8
-		y := (instruction & 0x00F0) >> 4
-		size := instruction & 0x000F
-		cpu.DrawSprite(x, y, size)
-		cpu.PC += 2
-	case 0xE000:
-		// EX9E - SKP Vx
-		// Skip next instruction if key with the value of Vx is pressed
-		// EXA1 - SKNP Vx
-		// Skip next instruction if key with the value of Vx is not pressed
-		x := (instruction & 0x0F00) >> 8
-		opcodeLow := byte(instruction & 0x00FF)
-		keyState := cpu.Keys[cpu.V[x]]
-		if (keyState == true) && (opcodeLow == 0x9E) {
-			cpu.PC += 4
-		} else if (keyState == false) && (opcodeLow == 0xA1) {
-			cpu.PC += 4
-		} else {
-			cpu.PC += 2
-		}
-	case 0xF000:
-		// Various operations starting with F
-		x := (instruction & 0x0F00) >> 8
-		sel := instruction & 0x00FF
-		cpu.Perform0xFOperation(x, sel)
-		cpu.PC += 2
-	default:
-		// Unknown opcode
-		fmt.Printf("Unknown opcode: 0x%04X\n", instruction)
-		cpu.PC += 2
-	}
+	in := decode(instruction)
+	in.exec(cpu, &in)
 }
 
 // ClearScreen clears the display memory.
@@ -212,6 +133,16 @@ func (cpu *CPU) ClearScreen() {
 	for i := range cpu.Display {
 		cpu.Display[i] = 0
 	}
+	cpu.markAllDirty()
+}
+
+// DisplaySize returns the width and height of the display in the current mode.
+func (cpu *CPU) DisplaySize() (width, height int) {
+	d := cpu.Platform.Descriptor()
+	if cpu.Hires && d.Hires {
+		return 128, 64
+	}
+	return d.Width, d.Height
 }
 
 // ReturnFromSubroutine returns from a subroutine by popping the return address from the stack.
@@ -221,9 +152,27 @@ func (cpu *CPU) ReturnFromSubroutine() {
 	cpu.PC += 2 // Increment PC after return to avoid infinite loop
 }
 
-// GetDisplay returns the current state of the display.
-func (cpu *CPU) GetDisplay() [64 * 32]byte {
-	return cpu.Display
+// GetDisplay returns the current state of the display, one byte per pixel, row by row.
+// Its length depends on the display mode; see DisplaySize.
+func (cpu *CPU) GetDisplay() []byte {
+	width, height := cpu.DisplaySize()
+	display := make([]byte, width*height)
+	copy(display, cpu.Display[:])
+	return display
+}
+
+// DisplaySnapshot is a copy of the display, taken between instructions.
+// Renderers draw snapshots instead of reading Display while the CPU runs.
+type DisplaySnapshot struct {
+	Width  int
+	Height int
+	Pixels []byte // One byte per pixel (0 or 1), row by row
+}
+
+// Snapshot copies the display in its current mode.
+func (cpu *CPU) Snapshot() DisplaySnapshot {
+	width, height := cpu.DisplaySize()
+	return DisplaySnapshot{Width: width, Height: height, Pixels: cpu.GetDisplay()}
 }
 
 // SetKey sets the state of a key in the keypad.
@@ -249,7 +198,14 @@ func (cpu *CPU) GetSoundTimer() uint8 {
 }
 
 // UpdateTimers updates the delay and sound timers at 60Hz.
+// It is called once at the end of every frame, and counts the frames.
+// It is the vertical blank that releases a DXYN waiting with the vblank quirk.
 func (cpu *CPU) UpdateTimers() {
+	cpu.Frame++
+	if cpu.WaitingVBlank {
+		cpu.WaitingVBlank = false
+		cpu.vblank = true
+	}
 	if cpu.DelayTimer > 0 {
 		cpu.DelayTimer--
 	}
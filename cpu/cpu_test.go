@@ -0,0 +1,63 @@
+package cpu
+
+import "testing"
+
+// newTestCPU returns a CPU with the program loaded at 0x200.
+// It keeps the quirks of NewCPU; tests that depend on others set them.
+func newTestCPU(tb testing.TB, program ...uint16) *CPU {
+	tb.Helper()
+	cpu := NewCPU()
+	if err := cpu.LoadProgram(assemble(program...)); err != nil {
+		tb.Fatal(err)
+	}
+	return cpu
+}
+
+// assemble converts opcodes into program bytes.
+func assemble(opcodes ...uint16) []byte {
+	program := make([]byte, 0, 2*len(opcodes))
+	for _, opcode := range opcodes {
+		program = append(program, byte(opcode>>8), byte(opcode))
+	}
+	return program
+}
+
+// Benchmark program: a loop of register, memory and arithmetic instructions
+// that never draws or waits.
+var benchmarkLoop = []uint16{
+	0xA300, // 200: LD I, 300
+	0x6005, // 202: LD V0, 05
+	0x7101, // 204: ADD V1, 01
+	0x8014, // 206: ADD V0, V1
+	0xF355, // 208: LD [I], V3
+	0xF365, // 20A: LD V3, [I]
+	0x3100, // 20C: SE V1, 00
+	0x1204, // 20E: JP 204
+	0x1200, // 210: JP 200
+}
+
+// reportInstructionRate reports the instructions per second of a benchmark
+// that ran n instructions.
+func reportInstructionRate(b *testing.B, n int) {
+	b.ReportMetric(float64(n)/b.Elapsed().Seconds(), "instr/s")
+}
+
+func TestSeedRandom(t *testing.T) {
+	// 200: RND V0, FF; 202: JP 200
+	random := func(seed uint64) []byte {
+		cpu := newTestCPU(t, 0xC0FF, 0x1200)
+		cpu.SeedRandom(seed)
+		var bytes []byte
+		for i := 0; i < 16; i++ {
+			cpu.Run(2)
+			bytes = append(bytes, cpu.V[0])
+		}
+		return bytes
+	}
+	if a, b := random(42), random(42); string(a) != string(b) {
+		t.Errorf("seed 42 drew % X, then % X", a, b)
+	}
+	if a, b := random(42), random(43); string(a) == string(b) {
+		t.Errorf("seeds 42 and 43 both drew % X", a)
+	}
+}
@@ -0,0 +1,265 @@
+package cpu
+
+// instruction is an opcode decoded into its handler and operands.
+// A zero instruction (nil exec) is an empty cache entry. Handlers get a pointer
+// into the cache, so they read their operands before writing to memory, which
+// may invalidate their own entry.
+type instruction struct {
+	exec   func(cpu *CPU, in *instruction)
+	opcode uint16
+	x, y   uint16 // Register indices
+	n      uint16 // Lowest nibble
+	nn     byte   // Lowest byte
+	nnn    uint16 // Address
+}
+
+// decodeCache holds the decoded instruction at every address, so each opcode
+// is only decoded once. Write8 invalidates the entries a write to Memory
+// overlaps, which keeps self-modifying programs working; with a Bus, the
+// fetched opcode is compared with the cached one instead.
+type decodeCache [4096]instruction
+
+// Handlers of the instructions, by their highest nibble
+var handlers = [16]func(cpu *CPU, in *instruction){
+	0x0: execSystem,
+	0x1: execJump,
+	0x2: execCall,
+	0x3: execSkipEqualByte,
+	0x4: execSkipNotEqualByte,
+	0x5: execSkipEqual,
+	0x6: execLoadByte,
+	0x7: execAddByte,
+	0x8: execArithmetic,
+	0x9: execSkipNotEqual,
+	0xA: execLoadIndex,
+	0xB: execJumpOffset,
+	0xC: execRandom,
+	0xD: execDraw,
+	0xE: execSkipKey,
+	0xF: execF,
+}
+
+// decode splits an opcode into its handler and operands.
+func decode(opcode uint16) instruction {
+	in := instruction{
+		exec:   handlers[opcode>>12],
+		opcode: opcode,
+		x:      (opcode & 0x0F00) >> 8,
+		y:      (opcode & 0x00F0) >> 4,
+		n:      opcode & 0x000F,
+		nn:     byte(opcode & 0x00FF),
+		nnn:    opcode & 0x0FFF,
+	}
+	switch opcode {
+	case 0x00E0:
+		in.exec = execClear
+	case 0x00EE:
+		in.exec = execReturn
+	}
+	return in
+}
+
+// fetch returns the decoded instruction at PC.
+// A PC past the end of memory wraps around to its start.
+func (cpu *CPU) fetch() *instruction {
+	if cpu.decoded == nil {
+		cpu.decoded = new(decodeCache)
+	}
+	pc := cpu.PC
+	if cpu.Bus != nil {
+		// The bus may have changed behind the CPU's back
+		opcode := uint16(cpu.Bus.Read8(pc))<<8 | uint16(cpu.Bus.Read8(pc+1))
+		in := &cpu.decoded[pc&0xFFF]
+		if in.exec == nil || in.opcode != opcode {
+			*in = decode(opcode)
+		}
+		return in
+	}
+	in := &cpu.decoded[pc&0xFFF]
+	if in.exec == nil {
+		*in = decode(uint16(cpu.Memory[pc&0xFFF])<<8 | uint16(cpu.Memory[(pc+1)&0xFFF]))
+	}
+	return in
+}
+
+// invalidate drops the cached instructions that contain the byte at address.
+func (cpu *CPU) invalidate(address uint16) {
+	if cpu.decoded == nil {
+		return
+	}
+	cpu.decoded[address&0xFFF] = instruction{}
+	cpu.decoded[(address-1)&0xFFF] = instruction{}
+}
+
+// execClear clears the screen.
+func execClear(cpu *CPU, in *instruction) {
+	// 00E0 - CLS
+	// Clear the display
+	cpu.ClearScreen()
+	cpu.PC += 2
+}
+
+// execReturn returns from a subroutine.
+func execReturn(cpu *CPU, in *instruction) {
+	// 00EE - RET
+	// Return from a subroutine
+	cpu.ReturnFromSubroutine()
+}
+
+// execSystem runs the other instructions starting with 0x0.
+func execSystem(cpu *CPU, in *instruction) {
+	// SCHIP display and control instructions
+	cpu.PerformSuperChipOperation(in.opcode)
+	cpu.PC += 2
+}
+
+// execJump jumps to an address.
+func execJump(cpu *CPU, in *instruction) {
+	// 1NNN - JP addr
+	// Jump to location NNN
+	cpu.PC = in.nnn
+}
+
+// execCall calls a subroutine.
+func execCall(cpu *CPU, in *instruction) {
+	// 2NNN - CALL addr
+	// Call subroutine at NNN
+	cpu.Stack[cpu.SP] = cpu.PC
+	cpu.SP++
+	cpu.PC = in.nnn
+}
+
+// execSkipEqualByte skips the next instruction if a register equals a byte.
+func execSkipEqualByte(cpu *CPU, in *instruction) {
+	// 3XNN - SE Vx, byte
+	// Skip next instruction if Vx = NN
+	if cpu.V[in.x] == in.nn {
+		cpu.PC += 4
+	} else {
+		cpu.PC += 2
+	}
+}
+
+// execSkipNotEqualByte skips the next instruction if a register differs from a byte.
+func execSkipNotEqualByte(cpu *CPU, in *instruction) {
+	// 4XNN - SNE Vx, byte
+	// Skip next instruction if Vx != NN
+	if cpu.V[in.x] != in.nn {
+		cpu.PC += 4
+	} else {
+		cpu.PC += 2
+	}
+}
+
+// execSkipEqual skips the next instruction if two registers are equal.
+func execSkipEqual(cpu *CPU, in *instruction) {
+	// 5XY0 - SE Vx, Vy
+	// Skip next instruction if Vx = Vy
+	if cpu.V[in.x] == cpu.V[in.y] {
+		cpu.PC += 4
+	} else {
+		cpu.PC += 2
+	}
+}
+
+// execLoadByte loads a byte into a register.
+func execLoadByte(cpu *CPU, in *instruction) {
+	// 6XNN - LD Vx, byte
+	// Set Vx = NN
+	cpu.V[in.x] = in.nn
+	cpu.PC += 2
+}
+
+// execAddByte adds a byte to a register.
+func execAddByte(cpu *CPU, in *instruction) {
+	// 7XNN - ADD Vx, byte
+	// Set Vx = Vx + NN
+	cpu.V[in.x] += in.nn
+	cpu.PC += 2
+}
+
+// execArithmetic runs an arithmetic or logical operation.
+func execArithmetic(cpu *CPU, in *instruction) {
+	// 8XYN - Various arithmetic and logical operations
+	cpu.PerformArithmeticOperation(in.x, in.y, in.n)
+	cpu.PC += 2
+}
+
+// execSkipNotEqual skips the next instruction if two registers differ.
+func execSkipNotEqual(cpu *CPU, in *instruction) {
+	// 9XY0 - SNE Vx, Vy
+	// Skip next instruction if Vx != Vy
+	if cpu.V[in.x] != cpu.V[in.y] {
+		cpu.PC += 4
+	} else {
+		cpu.PC += 2
+	}
+}
+
+// execLoadIndex loads an address into I.
+func execLoadIndex(cpu *CPU, in *instruction) {
+	// ANNN - LD I, addr
+	// Set I = NNN
+	cpu.I = in.nnn
+	cpu.PC += 2
+}
+
+// execJumpOffset jumps to an address plus a register.
+func execJumpOffset(cpu *CPU, in *instruction) {
+	// BNNN - JP V0, addr
+	// Jump to location NNN + V0
+	// With the jump quirk (BXNN), jump to location XNN + VX
+	offset := cpu.V[0]
+	if cpu.Quirks.Jump {
+		offset = cpu.V[in.x]
+	}
+	cpu.PC = in.nnn + uint16(offset)
+}
+
+// execRandom loads a masked random byte into a register.
+func execRandom(cpu *CPU, in *instruction) {
+	// CXNN - RND Vx, byte
+	// Set Vx = random byte AND NN
+	random := byte(cpu.random.Uint64())
+	cpu.V[in.x] = random & in.nn
+	cpu.PC += 2
+}
+
+// execDraw draws a sprite.
+func execDraw(cpu *CPU, in *instruction) {
+	// DXYN - DRW Vx, Vy, nibble
+	// Display N-byte sprite starting at memory location I at (Vx, Vy)
+	// DXY0 draws a 16x16 sprite (SCHIP)
+	// With the vblank quirk, wait for the vertical blank first: the CPU stops
+	// until UpdateTimers ends the frame, then runs the instruction again.
+	if cpu.Quirks.VBlank && !cpu.vblank {
+		cpu.WaitingVBlank = true
+		return
+	}
+	cpu.vblank = false
+	cpu.DrawSprite(in.x, in.y, in.n)
+	cpu.PC += 2
+}
+
+// execSkipKey skips the next instruction depending on the state of a key.
+func execSkipKey(cpu *CPU, in *instruction) {
+	// EX9E - SKP Vx
+	// Skip next instruction if key with the value of Vx is pressed
+	// EXA1 - SKNP Vx
+	// Skip next instruction if key with the value of Vx is not pressed
+	keyState := cpu.Keys[cpu.V[in.x]]
+	if keyState && in.nn == 0x9E {
+		cpu.PC += 4
+	} else if !keyState && in.nn == 0xA1 {
+		cpu.PC += 4
+	} else {
+		cpu.PC += 2
+	}
+}
+
+// execF runs the instructions starting with 0xF.
+func execF(cpu *CPU, in *instruction) {
+	// Various operations starting with F
+	cpu.Perform0xFOperation(in.x, uint16(in.nn))
+	cpu.PC += 2
+}
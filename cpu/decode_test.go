@@ -0,0 +1,276 @@
+package cpu
+
+import "testing"
+
+// Self-modifying programs: a target instruction runs once, is overwritten by
+// FX55 or FX33, and then runs again. A stale decoded instruction would keep
+// looping back to 0x204 instead.
+var selfModifyingTests = []struct {
+	name    string
+	program []uint16
+	check   func(t *testing.T, cpu *CPU)
+}{
+	{"FX55", []uint16{
+		0x6064, // 200: LD V0, 64
+		0x6177, // 202: LD V1, 77
+		0xA212, // 204: LD I, 212
+		0x7201, // 206: ADD V2, 01
+		0x3202, // 208: SE V2, 02
+		0x1212, // 20A: JP 212
+		0xF155, // 20C: LD [I], V1 - overwrites 212 with 6477
+		0x1212, // 20E: JP 212
+		0x0000, // 210
+		0x1206, // 212: JP 206, then LD V4, 77
+		0x1214, // 214: JP 214
+	}, func(t *testing.T, cpu *CPU) {
+		if cpu.V[4] != 0x77 {
+			t.Errorf("V4 = %02X, want 77 from the rewritten instruction", cpu.V[4])
+		}
+	}},
+	{"FX33", []uint16{
+		0x607B, // 200: LD V0, 7B (123)
+		0xA210, // 202: LD I, 210
+		0x7201, // 204: ADD V2, 01
+		0x3202, // 206: SE V2, 02
+		0x1210, // 208: JP 210
+		0xF033, // 20A: LD B, V0 - overwrites 210 with 0102 and 212 with 03
+		0x1210, // 20C: JP 210
+		0x0000, // 20E
+		0x1204, // 210: JP 204, then SYS 102
+		0x1204, // 212: JP 204, then SYS 304
+		0x1214, // 214: JP 214
+	}, nil},
+}
+
+func TestSelfModifyingCode(t *testing.T) {
+	modes := []struct {
+		name string
+		run  func(cpu *CPU, n int)
+	}{
+		{"Step", func(cpu *CPU, n int) {
+			for range n {
+				cpu.Step()
+			}
+		}},
+		{"Run", func(cpu *CPU, n int) { cpu.Run(n) }},
+	}
+	for _, tt := range selfModifyingTests {
+		for _, mode := range modes {
+			t.Run(tt.name+"/"+mode.name, func(t *testing.T) {
+				cpu := newTestCPU(t, tt.program...)
+				mode.run(cpu, 100)
+				if cpu.V[2] != 2 || cpu.PC != 0x214 {
+					t.Fatalf("V2 = %d, PC = %03X; want 2, 214: the old instruction ran again", cpu.V[2], cpu.PC)
+				}
+				if tt.check != nil {
+					tt.check(t, cpu)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeMatchesExecuteInstruction(t *testing.T) {
+	// The cached and the uncached path run the same program to the same state
+	cached := newTestCPU(t, benchmarkLoop...)
+	uncached := newTestCPU(t, benchmarkLoop...)
+	for i := range 1000 {
+		cached.Step()
+		uncached.ExecuteInstruction(uint16(uncached.Memory[uncached.PC])<<8 | uint16(uncached.Memory[uncached.PC+1]))
+		if cached.PC != uncached.PC || cached.V != uncached.V || cached.I != uncached.I {
+			t.Fatalf("instruction %d: cached PC=%03X V=%X I=%03X, uncached PC=%03X V=%X I=%03X",
+				i, cached.PC, cached.V, cached.I, uncached.PC, uncached.V, uncached.I)
+		}
+	}
+}
+
+// executeSwitch is the interpreter before the decode cache, kept as a
+// reference for the decoded handlers and as the baseline of the benchmarks:
+// a switch on the opcode that extracts the operands of every instruction.
+func executeSwitch(cpu *CPU, instruction uint16) {
+	cpu.CurrentOpcode = instruction
+	switch instruction & 0xF000 {
+	case 0x0000:
+		if instruction == 0x00E0 {
+			cpu.ClearScreen()
+			cpu.PC += 2
+		} else if instruction == 0x00EE {
+			cpu.ReturnFromSubroutine()
+		} else {
+			cpu.PerformSuperChipOperation(instruction)
+			cpu.PC += 2
+		}
+	case 0x1000:
+		cpu.PC = instruction & 0x0FFF
+	case 0x2000:
+		cpu.Stack[cpu.SP] = cpu.PC
+		cpu.SP++
+		cpu.PC = instruction & 0x0FFF
+	case 0x3000:
+		x := (instruction & 0x0F00) >> 8
+		if cpu.V[x] == byte(instruction&0x00FF) {
+			cpu.PC += 4
+		} else {
+			cpu.PC += 2
+		}
+	case 0x4000:
+		x := (instruction & 0x0F00) >> 8
+		if cpu.V[x] != byte(instruction&0x00FF) {
+			cpu.PC += 4
+		} else {
+			cpu.PC += 2
+		}
+	case 0x5000:
+		x, y := (instruction&0x0F00)>>8, (instruction&0x00F0)>>4
+		if cpu.V[x] == cpu.V[y] {
+			cpu.PC += 4
+		} else {
+			cpu.PC += 2
+		}
+	case 0x6000:
+		cpu.V[(instruction&0x0F00)>>8] = byte(instruction & 0x00FF)
+		cpu.PC += 2
+	case 0x7000:
+		cpu.V[(instruction&0x0F00)>>8] += byte(instruction & 0x00FF)
+		cpu.PC += 2
+	case 0x8000:
+		x, y := (instruction&0x0F00)>>8, (instruction&0x00F0)>>4
+		cpu.PerformArithmeticOperation(x, y, instruction&0x000F)
+		cpu.PC += 2
+	case 0x9000:
+		x, y := (instruction&0x0F00)>>8, (instruction&0x00F0)>>4
+		if cpu.V[x] != cpu.V[y] {
+			cpu.PC += 4
+		} else {
+			cpu.PC += 2
+		}
+	case 0xA000:
+		cpu.I = instruction & 0x0FFF
+		cpu.PC += 2
+	case 0xB000:
+		offset := cpu.V[0]
+		if cpu.Quirks.Jump {
+			offset = cpu.V[(instruction&0x0F00)>>8]
+		}
+		cpu.PC = (instruction & 0x0FFF) + uint16(offset)
+	case 0xC000:
+		cpu.V[(instruction&0x0F00)>>8] = byte(cpu.random.Uint64()) & byte(instruction&0x00FF)
+		cpu.PC += 2
+	case 0xD000:
+		if cpu.Quirks.VBlank && !cpu.vblank {
+			cpu.WaitingVBlank = true
+			return
+		}
+		cpu.vblank = false
+		cpu.DrawSprite((instruction&0x0F00)>>8, (instruction&0x00F0)>>4, instruction&0x000F)
+		cpu.PC += 2
+	case 0xE000:
+		keyState := cpu.Keys[cpu.V[(instruction&0x0F00)>>8]]
+		opcodeLow := byte(instruction & 0x00FF)
+		if keyState && opcodeLow == 0x9E {
+			cpu.PC += 4
+		} else if !keyState && opcodeLow == 0xA1 {
+			cpu.PC += 4
+		} else {
+			cpu.PC += 2
+		}
+	case 0xF000:
+		cpu.Perform0xFOperation((instruction&0x0F00)>>8, instruction&0x00FF)
+		cpu.PC += 2
+	}
+}
+
+// observable is the state an instruction can change, comparable with ==.
+type observable struct {
+	PC, I         uint16
+	V             [16]byte
+	SP            uint8
+	Stack         [16]uint16
+	Memory        [4096]byte
+	Display       [128 * 64]byte
+	Hires, Exited bool
+	WaitingVBlank bool
+	KeyWait       KeyWait
+	Flags         [16]byte
+	Timers        [2]uint8
+	Fault         bool
+}
+
+func observe(cpu *CPU) observable {
+	return observable{
+		PC: cpu.PC, I: cpu.I, V: cpu.V, SP: cpu.SP, Stack: cpu.Stack,
+		Memory: cpu.Memory, Display: cpu.Display, Hires: cpu.Hires, Exited: cpu.Exited,
+		WaitingVBlank: cpu.WaitingVBlank, KeyWait: cpu.KeyWait, Flags: cpu.Flags,
+		Timers: [2]uint8{cpu.DelayTimer, cpu.SoundTimer}, Fault: cpu.Fault != nil,
+	}
+}
+
+func TestDecodeMatchesSwitch(t *testing.T) {
+	// Every opcode leaves the same state with both interpreters; the copies
+	// of the CPU draw the same random bytes
+	base := NewCPU()
+	base.PC, base.I, base.SP = 0x400, 0x300, 2
+	base.Stack[0], base.Stack[1] = 0x204, 0x30A
+	for i := range base.V {
+		base.V[i] = byte(i*7) % 16 // Valid keys, for EX9E and EXA1
+		base.Memory[0x300+i] = byte(i * 17)
+	}
+	base.Keys[3], base.Keys[0xE] = true, true
+	for opcode := 0; opcode <= 0xFFFF; opcode++ {
+		decoded, reference := *base, *base
+		decoded.ExecuteInstruction(uint16(opcode))
+		executeSwitch(&reference, uint16(opcode))
+		if got, want := observe(&decoded), observe(&reference); got != want {
+			t.Fatalf("%04X: decoded PC=%03X V=%X I=%03X SP=%d, reference PC=%03X V=%X I=%03X SP=%d",
+				opcode, got.PC, got.V, got.I, got.SP, want.PC, want.V, want.I, want.SP)
+		}
+	}
+}
+
+// Instructions per benchmark iteration
+const benchmarkInstructions = 1000
+
+// BenchmarkSwitch runs the interpreter before the decode cache: every
+// instruction is fetched through Read8 and decoded by a switch.
+func BenchmarkSwitch(b *testing.B) {
+	cpu := newTestCPU(b, benchmarkLoop...)
+	for range b.N {
+		for range benchmarkInstructions {
+			cpu.recordExecute(cpu.PC)
+			executeSwitch(cpu, uint16(cpu.Read8(cpu.PC))<<8|uint16(cpu.Read8(cpu.PC+1)))
+		}
+	}
+	reportInstructionRate(b, b.N*benchmarkInstructions)
+}
+
+// BenchmarkExecuteInstruction decodes every instruction into its handler
+// without the decode cache.
+func BenchmarkExecuteInstruction(b *testing.B) {
+	cpu := newTestCPU(b, benchmarkLoop...)
+	for range b.N {
+		for range benchmarkInstructions {
+			cpu.ExecuteInstruction(uint16(cpu.Memory[cpu.PC])<<8 | uint16(cpu.Memory[cpu.PC+1]))
+		}
+	}
+	reportInstructionRate(b, b.N*benchmarkInstructions)
+}
+
+// BenchmarkStep runs the instructions from the decode cache one at a time.
+func BenchmarkStep(b *testing.B) {
+	cpu := newTestCPU(b, benchmarkLoop...)
+	for range b.N {
+		for range benchmarkInstructions {
+			cpu.Step()
+		}
+	}
+	reportInstructionRate(b, b.N*benchmarkInstructions)
+}
+
+// BenchmarkRun runs the instructions from the decode cache in a batch.
+func BenchmarkRun(b *testing.B) {
+	cpu := newTestCPU(b, benchmarkLoop...)
+	for range b.N {
+		cpu.Run(benchmarkInstructions)
+	}
+	reportInstructionRate(b, b.N*benchmarkInstructions)
+}
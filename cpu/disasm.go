@@ -0,0 +1,92 @@
+package cpu
+
+import "fmt"
+
+// Disassemble returns the assembly mnemonic of a CHIP-8 instruction,
+// using the same notation as the comments of the instruction handlers.
+// Unknown instructions are shown as a data word.
+// Parameters:
+//   - instruction: The 16-bit instruction to disassemble
+func Disassemble(instruction uint16) string {
+	x := (instruction & 0x0F00) >> 8
+	y := (instruction & 0x00F0) >> 4
+	n := instruction & 0x000F
+	nn := instruction & 0x00FF
+	nnn := instruction & 0x0FFF
+
+	switch instruction & 0xF000 {
+	case 0x0000:
+		switch {
+		case instruction == 0x00E0:
+			return "CLS"
+		case instruction == 0x00EE:
+			return "RET"
+		case instruction&0xFFF0 == 0x00C0:
+			return fmt.Sprintf("SCD %d", n)
+		case instruction == 0x00FB:
+			return "SCR"
+		case instruction == 0x00FC:
+			return "SCL"
+		case instruction == 0x00FD:
+			return "EXIT"
+		case instruction == 0x00FE:
+			return "LOW"
+		case instruction == 0x00FF:
+			return "HIGH"
+		}
+		return fmt.Sprintf("SYS 0x%03X", nnn)
+	case 0x1000:
+		return fmt.Sprintf("JP 0x%03X", nnn)
+	case 0x2000:
+		return fmt.Sprintf("CALL 0x%03X", nnn)
+	case 0x3000:
+		return fmt.Sprintf("SE V%X, 0x%02X", x, nn)
+	case 0x4000:
+		return fmt.Sprintf("SNE V%X, 0x%02X", x, nn)
+	case 0x5000:
+		if n == 0 {
+			return fmt.Sprintf("SE V%X, V%X", x, y)
+		}
+	case 0x6000:
+		return fmt.Sprintf("LD V%X, 0x%02X", x, nn)
+	case 0x7000:
+		return fmt.Sprintf("ADD V%X, 0x%02X", x, nn)
+	case 0x8000:
+		mnemonics := map[uint16]string{
+			0x0: "LD", 0x1: "OR", 0x2: "AND", 0x3: "XOR", 0x4: "ADD",
+			0x5: "SUB", 0x6: "SHR", 0x7: "SUBN", 0xE: "SHL",
+		}
+		if mnemonic, ok := mnemonics[n]; ok {
+			return fmt.Sprintf("%s V%X, V%X", mnemonic, x, y)
+		}
+	case 0x9000:
+		if n == 0 {
+			return fmt.Sprintf("SNE V%X, V%X", x, y)
+		}
+	case 0xA000:
+		return fmt.Sprintf("LD I, 0x%03X", nnn)
+	case 0xB000:
+		return fmt.Sprintf("JP V0, 0x%03X", nnn)
+	case 0xC000:
+		return fmt.Sprintf("RND V%X, 0x%02X", x, nn)
+	case 0xD000:
+		return fmt.Sprintf("DRW V%X, V%X, %d", x, y, n)
+	case 0xE000:
+		switch nn {
+		case 0x9E:
+			return fmt.Sprintf("SKP V%X", x)
+		case 0xA1:
+			return fmt.Sprintf("SKNP V%X", x)
+		}
+	case 0xF000:
+		formats := map[uint16]string{
+			0x07: "LD V%X, DT", 0x0A: "LD V%X, K", 0x15: "LD DT, V%X", 0x18: "LD ST, V%X",
+			0x1E: "ADD I, V%X", 0x29: "LD F, V%X", 0x30: "LD HF, V%X", 0x33: "LD B, V%X",
+			0x55: "LD [I], V%X", 0x65: "LD V%X, [I]", 0x75: "LD R, V%X", 0x85: "LD V%X, R",
+		}
+		if format, ok := formats[nn]; ok {
+			return fmt.Sprintf(format, x)
+		}
+	}
+	return fmt.Sprintf("DW 0x%04X", instruction)
+}
@@ -2,37 +2,194 @@ package cpu
 
 // DrawSprite draws a sprite at coordinates (VX, VY) with N bytes of sprite data.
 // The sprite is drawn using XOR logic, and VF is set to 1 if any pixels are flipped from set to unset.
+// A size of 0 draws a 16x16 sprite made of 32 bytes, two per row (SCHIP).
+// The starting coordinates wrap around the display. The rest of the sprite is
+// clipped at the display edges, or wraps around with the wrap quirk.
+// With the collision rows quirk in hires mode, VF is set to the number of rows
+// that collided or were clipped at the bottom edge instead.
+// Sprite data past the end of memory is read according to the memory policy.
 // Parameters:
 //   - x: The register index containing the X coordinate
 //   - y: The register index containing the Y coordinate
 //   - size: The number of bytes of sprite data to draw (height of sprite)
 func (cpu *CPU) DrawSprite(x, y, size uint16) {
-	xPos := uint16(cpu.V[x]) // X coordinate from register VX
-	yPos := uint16(cpu.V[y]) // Y coordinate from register VY
-	cpu.V[0xF] = 0           // Reset collision flag
+	width, height := cpu.DisplaySize()
+	xPos := int(cpu.V[x]) % width  // X coordinate from register VX
+	yPos := int(cpu.V[y]) % height // Y coordinate from register VY
 
-	// Loop through each row of the sprite
-	for j := uint16(0); j < size; j++ {
-		// Get the sprite data for this row
-		pixel := cpu.Memory[cpu.I+j]
+	spriteWidth, rowBytes := 8, uint16(1)
+	if size == 0 {
+		size, spriteWidth, rowBytes = 16, 16, 2
+	}
+	if !cpu.checkIndexed(int(size * rowBytes)) {
+		return
+	}
+	cpu.recordRead(cpu.I, size*rowBytes)
+	cpu.markSprite(xPos, yPos, spriteWidth, int(size))
+
+	collided, clipped := 0, 0 // Rows with a collision, and rows clipped at the bottom
+	for j := 0; j < int(size); j++ {
+		posY := yPos + j
+		if posY >= height {
+			if !cpu.Quirks.Wrap {
+				clipped = int(size) - j
+				break
+			}
+			posY %= height
+		}
+
+		// Get the sprite data for this row, left-aligned in 16 bits
+		row := j * int(rowBytes)
+		pixel := uint16(cpu.Read8(cpu.indexed(row))) << 8
+		if rowBytes == 2 {
+			pixel |= uint16(cpu.Read8(cpu.indexed(row + 1)))
+		}
 
 		// Loop through each bit in the sprite data
-		for i := uint16(0); i < 8; i++ {
+		collision := false
+		for i := 0; i < spriteWidth; i++ {
 			// Check if the current pixel is set in the sprite data (1)
-			if (pixel & (0x80 >> i)) != 0 {
-				// Calculate the x and y position with wrapping
-				posX := (xPos + i) % 64
-				posY := (yPos + j) % 32
-				idx := posY*64 + posX
-
-				// Check for collision and set VF if a pixel is flipped
-				if cpu.Display[idx] == 1 {
-					cpu.V[0xF] = 1 // Set collision flag
+			if (pixel & (0x8000 >> i)) == 0 {
+				continue
+			}
+			posX := xPos + i
+			if posX >= width {
+				if !cpu.Quirks.Wrap {
+					break
 				}
+				posX %= width
+			}
+			idx := posY*width + posX
+
+			// Check for collision if a pixel is flipped off
+			if cpu.Display[idx] == 1 {
+				collision = true
+			}
+
+			// XOR the pixel in the display
+			cpu.Display[idx] ^= 1
+		}
+		if collision {
+			collided++
+		}
+	}
+
+	switch {
+	case cpu.Quirks.CollisionRows && cpu.Hires:
+		cpu.V[0xF] = byte(collided + clipped)
+	case collided > 0:
+		cpu.V[0xF] = 1
+	default:
+		cpu.V[0xF] = 0
+	}
+}
+
+// ScrollDown scrolls the display down by n pixels (SCHIP 00CN).
+func (cpu *CPU) ScrollDown(n int) {
+	cpu.markAllDirty()
+	width, height := cpu.DisplaySize()
+	for y := height - 1; y >= 0; y-- {
+		for x := 0; x < width; x++ {
+			var pixel byte
+			if y-n >= 0 {
+				pixel = cpu.Display[(y-n)*width+x]
+			}
+			cpu.Display[y*width+x] = pixel
+		}
+	}
+}
 
-				// XOR the pixel in the display
-				cpu.Display[idx] ^= 1
+// ScrollRight scrolls the display right by n pixels (SCHIP 00FB).
+func (cpu *CPU) ScrollRight(n int) {
+	cpu.markAllDirty()
+	width, height := cpu.DisplaySize()
+	for y := 0; y < height; y++ {
+		for x := width - 1; x >= 0; x-- {
+			var pixel byte
+			if x-n >= 0 {
+				pixel = cpu.Display[y*width+x-n]
 			}
+			cpu.Display[y*width+x] = pixel
 		}
 	}
 }
+
+// ScrollLeft scrolls the display left by n pixels (SCHIP 00FC).
+func (cpu *CPU) ScrollLeft(n int) {
+	cpu.markAllDirty()
+	width, height := cpu.DisplaySize()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var pixel byte
+			if x+n < width {
+				pixel = cpu.Display[y*width+x+n]
+			}
+			cpu.Display[y*width+x] = pixel
+		}
+	}
+}
+
+// Rect is a rectangle of display pixels.
+type Rect struct {
+	X, Y, Width, Height int
+}
+
+// maxDirtyRects is the number of dirty rectangles kept before the whole
+// display is marked dirty instead
+const maxDirtyRects = 32
+
+// TakeDirty returns the regions of the display that changed since the last
+// call, in the coordinates of the current display mode, and forgets them.
+// Clearing, scrolling or switching the display mode marks the whole display.
+// Renderers use it to redraw only what changed; no regions means that the
+// display is unchanged.
+func (cpu *CPU) TakeDirty() []Rect {
+	dirty := cpu.dirty
+	cpu.dirty = nil
+	return dirty
+}
+
+// markAllDirty marks the whole display as changed.
+func (cpu *CPU) markAllDirty() {
+	width, height := cpu.DisplaySize()
+	cpu.dirty = append(cpu.dirty[:0], Rect{0, 0, width, height})
+}
+
+// markSprite marks the area of a sprite drawn at (x, y) as changed.
+// Parts of the sprite that wrap around the display edges are marked separately.
+func (cpu *CPU) markSprite(x, y, spriteWidth, spriteHeight int) {
+	width, height := cpu.DisplaySize()
+	for _, column := range cpu.spriteSpans(x, spriteWidth, width) {
+		for _, row := range cpu.spriteSpans(y, spriteHeight, height) {
+			cpu.markDirty(Rect{column[0], row[0], column[1], row[1]})
+		}
+	}
+}
+
+// spriteSpans returns the parts of the span [start, start+length) that lie
+// inside [0, size) after clipping or wrapping; each part is a start and a length.
+func (cpu *CPU) spriteSpans(start, length, size int) [][2]int {
+	length = min(length, size)
+	switch {
+	case start+length <= size:
+		return [][2]int{{start, length}}
+	case cpu.Quirks.Wrap:
+		return [][2]int{{start, size - start}, {0, start + length - size}}
+	default:
+		return [][2]int{{start, size - start}}
+	}
+}
+
+// markDirty records a changed region. When too many regions have piled up,
+// the whole display is marked instead.
+func (cpu *CPU) markDirty(r Rect) {
+	width, height := cpu.DisplaySize()
+	if len(cpu.dirty) > 0 && cpu.dirty[0] == (Rect{0, 0, width, height}) {
+		return // Already covered
+	}
+	if len(cpu.dirty) >= maxDirtyRects {
+		cpu.markAllDirty()
+		return
+	}
+	cpu.dirty = append(cpu.dirty, r)
+}
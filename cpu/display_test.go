@@ -0,0 +1,216 @@
+package cpu
+
+import "testing"
+
+// Address of the sprite data of the drawing tests: 32 bytes of 0xFF, enough
+// for any sprite to be a solid block
+const testSprite = 0x300
+
+// newDrawCPU returns a CPU in the given display mode, with I pointing at a
+// solid sprite.
+func newDrawCPU(hires, wrap bool) *CPU {
+	cpu := NewCPU()
+	cpu.Hires = hires
+	cpu.Quirks.Wrap = wrap
+	for i := range 32 {
+		cpu.Memory[testSprite+i] = 0xFF
+	}
+	cpu.I = testSprite
+	return cpu
+}
+
+// draw draws a sprite of size rows at (x, y) with V0 and V1.
+func draw(cpu *CPU, x, y byte, size uint16) {
+	cpu.V[0], cpu.V[1] = x, y
+	cpu.DrawSprite(0, 1, size)
+}
+
+// litPixels returns the coordinates of the lit pixels.
+func litPixels(cpu *CPU) map[[2]int]bool {
+	width, height := cpu.DisplaySize()
+	lit := make(map[[2]int]bool)
+	for y := range height {
+		for x := range width {
+			if cpu.Display[y*width+x] == 1 {
+				lit[[2]int{x, y}] = true
+			}
+		}
+	}
+	return lit
+}
+
+// block returns the coordinates of the pixels of a rectangle.
+func block(x, y, width, height int) map[[2]int]bool {
+	pixels := make(map[[2]int]bool)
+	for dy := range height {
+		for dx := range width {
+			pixels[[2]int{x + dx, y + dy}] = true
+		}
+	}
+	return pixels
+}
+
+// union merges sets of pixel coordinates.
+func union(sets ...map[[2]int]bool) map[[2]int]bool {
+	pixels := make(map[[2]int]bool)
+	for _, set := range sets {
+		for p := range set {
+			pixels[p] = true
+		}
+	}
+	return pixels
+}
+
+func TestDrawSpriteEdges(t *testing.T) {
+	tests := []struct {
+		name  string
+		hires bool
+		wrap  bool
+		x, y  byte
+		size  uint16
+		want  map[[2]int]bool
+	}{
+		{"inside", false, false, 10, 5, 3, block(10, 5, 8, 3)},
+		{"right edge clipped", false, false, 60, 0, 2, block(60, 0, 4, 2)},
+		{"right edge wrapped", false, true, 60, 0, 2, union(block(60, 0, 4, 2), block(0, 0, 4, 2))},
+		{"bottom edge clipped", false, false, 0, 30, 4, block(0, 30, 8, 2)},
+		{"bottom edge wrapped", false, true, 0, 30, 4, union(block(0, 30, 8, 2), block(0, 0, 8, 2))},
+		{"corner clipped", false, false, 62, 31, 3, block(62, 31, 2, 1)},
+		{"corner wrapped", false, true, 62, 31, 3, union(
+			block(62, 31, 2, 1), block(0, 31, 6, 1), block(62, 0, 2, 2), block(0, 0, 6, 2))},
+		{"start coordinates wrap", false, false, 64 + 2, 32 + 3, 1, block(2, 3, 8, 1)},
+		{"hires right edge clipped", true, false, 124, 0, 1, block(124, 0, 4, 1)},
+		{"hires bottom edge wrapped", true, true, 0, 62, 3, union(block(0, 62, 8, 2), block(0, 0, 8, 1))},
+		{"16x16", true, false, 10, 20, 0, block(10, 20, 16, 16)},
+		{"16x16 clipped", true, false, 120, 56, 0, block(120, 56, 8, 8)},
+		{"16x16 wrapped", true, true, 120, 56, 0, union(
+			block(120, 56, 8, 8), block(0, 56, 8, 8), block(120, 0, 8, 8), block(0, 0, 8, 8))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu := newDrawCPU(tt.hires, tt.wrap)
+			draw(cpu, tt.x, tt.y, tt.size)
+			got := litPixels(cpu)
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d pixels lit, want %d", len(got), len(tt.want))
+			}
+			for p := range tt.want {
+				if !got[p] {
+					t.Fatalf("pixel %v is not lit", p)
+				}
+			}
+			if cpu.V[0xF] != 0 {
+				t.Errorf("VF = %d, want 0 on an empty display", cpu.V[0xF])
+			}
+
+			// Drawing the sprite again erases it and collides
+			draw(cpu, tt.x, tt.y, tt.size)
+			if n := len(litPixels(cpu)); n != 0 {
+				t.Errorf("%d pixels lit after drawing the sprite twice, want 0", n)
+			}
+			if cpu.V[0xF] != 1 {
+				t.Errorf("VF = %d after a collision, want 1", cpu.V[0xF])
+			}
+		})
+	}
+}
+
+func TestDrawSpriteCollisionRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		hires bool
+		wrap  bool
+		x, y  byte
+		size  uint16
+		first byte // VF after drawing on an empty display
+		again byte // VF after drawing the same sprite again
+	}{
+		// D01A at y=60 on 128x64: 4 rows are drawn and 6 are clipped
+		{"hires clipped", true, false, 0, 60, 10, 6, 10},
+		{"hires inside", true, false, 0, 0, 10, 0, 10},
+		{"hires 16x16 clipped", true, false, 0, 56, 0, 8, 16},
+		{"hires wrapped", true, true, 0, 60, 10, 0, 10},
+		{"lores counts no rows", false, false, 0, 28, 10, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu := newDrawCPU(tt.hires, tt.wrap)
+			cpu.Quirks.CollisionRows = true
+			draw(cpu, tt.x, tt.y, tt.size)
+			if cpu.V[0xF] != tt.first {
+				t.Errorf("VF = %d on an empty display, want %d", cpu.V[0xF], tt.first)
+			}
+			draw(cpu, tt.x, tt.y, tt.size)
+			if cpu.V[0xF] != tt.again {
+				t.Errorf("VF = %d after colliding on every row, want %d", cpu.V[0xF], tt.again)
+			}
+		})
+	}
+}
+
+func TestDrawSpriteMarksDirty(t *testing.T) {
+	cpu := newDrawCPU(false, false)
+	cpu.TakeDirty()
+	draw(cpu, 60, 30, 4)
+	dirty := cpu.TakeDirty()
+	if len(dirty) == 0 {
+		t.Fatal("drawing a sprite marked nothing dirty")
+	}
+	for p := range litPixels(cpu) {
+		covered := false
+		for _, r := range dirty {
+			covered = covered || p[0] >= r.X && p[0] < r.X+r.Width && p[1] >= r.Y && p[1] < r.Y+r.Height
+		}
+		if !covered {
+			t.Errorf("lit pixel %v is outside the dirty regions %v", p, dirty)
+		}
+	}
+}
+
+func TestDrawWaitsForVBlank(t *testing.T) {
+	cpu := newTestCPU(t,
+		0xF029, // 200: LD F, V0 (the 0 digit)
+		0xD015, // 202: DRW V0, V1, 5
+		0xD015, // 204: DRW V0, V1, 5
+		0x1206, // 206: JP 206
+	)
+	cpu.Quirks.VBlank = true
+	lit := func() bool { return cpu.Display[0] == 1 }
+
+	// The first DXYN waits for the end of the frame; Run counts the wait
+	if n := cpu.Run(10); n != 2 || !cpu.WaitingVBlank || cpu.PC != 0x202 || lit() {
+		t.Fatalf("frame 1: ran %d instructions, waiting %t, PC %03X, lit %t; want 2, true, 202, false",
+			n, cpu.WaitingVBlank, cpu.PC, lit())
+	}
+	cpu.Step()
+	if cpu.PC != 0x202 {
+		t.Fatalf("Step ran %03X while waiting for the vertical blank", cpu.PC)
+	}
+
+	// The vertical blank releases it; the second DXYN waits for the next one
+	cpu.UpdateTimers()
+	if cpu.WaitingVBlank {
+		t.Fatal("UpdateTimers didn't release the waiting DXYN")
+	}
+	if n := cpu.Run(10); n != 2 || !cpu.WaitingVBlank || cpu.PC != 0x204 || !lit() {
+		t.Fatalf("frame 2: ran %d instructions, waiting %t, PC %03X, lit %t; want 2, true, 204, true",
+			n, cpu.WaitingVBlank, cpu.PC, lit())
+	}
+
+	cpu.UpdateTimers()
+	if n := cpu.Run(10); n != 10 || cpu.WaitingVBlank || cpu.PC != 0x206 || lit() {
+		t.Fatalf("frame 3: ran %d instructions, waiting %t, PC %03X, lit %t; want 10, false, 206, false",
+			n, cpu.WaitingVBlank, cpu.PC, lit())
+	}
+}
+
+func TestDrawWithoutVBlank(t *testing.T) {
+	cpu := newTestCPU(t, 0xF029, 0xD015, 0xD015, 0x1206)
+	cpu.Quirks.VBlank = false
+	if n := cpu.Run(10); n != 10 || cpu.WaitingVBlank || cpu.PC != 0x206 {
+		t.Fatalf("ran %d instructions, waiting %t, PC %03X; want 10, false, 206", n, cpu.WaitingVBlank, cpu.PC)
+	}
+	if cpu.V[0xF] != 1 {
+		t.Errorf("VF = %d, want 1 after drawing the same sprite twice", cpu.V[0xF])
+	}
+}
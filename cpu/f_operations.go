@@ -16,21 +16,15 @@ func (cpu *CPU) Perform0xFOperation(x, sel uint16) {
 		cpu.V[x] = cpu.DelayTimer
 	case 0x0A:
 		// FX0A - LD Vx, K
-		// Wait for a key press, store the value of the key in Vx
-		// All execution stops until a key is pressed, then the value of that key is stored in Vx.
-		pressed := false
-		for i := 0; i < len(cpu.Keys); i++ {
-			if cpu.Keys[i] {
-				cpu.V[x] = byte(i)
-				pressed = true
-				break
-			}
-		}
-		if !pressed {
-			// If no key is pressed, decrement PC to run this instruction again
+		// Wait for a key press and release, store the value of the key in Vx
+		// All execution stops until a key is pressed and released again, then the value of that key is stored in Vx.
+		// The instruction runs again until then, keeping its progress in KeyWait.
+		if !cpu.waitForKey() {
 			cpu.PC -= 2
 			return // Important: Skip the automatic PC increment
 		}
+		cpu.V[x] = cpu.KeyWait.Key
+		cpu.KeyWait = KeyWait{}
 	case 0x15:
 		// FX15 - LD DT, Vx
 		// Set delay timer = Vx
@@ -56,35 +50,91 @@ func (cpu *CPU) Perform0xFOperation(x, sel uint16) {
 		// FX29 - LD F, Vx
 		// Set I = location of sprite for digit Vx
 		// The value of I is set to the location for the hexadecimal sprite corresponding to the value of Vx.
-		cpu.I = uint16(cpu.V[x]) * 5
+		// Only the lowest nibble of Vx is used.
+		cpu.I = cpu.FontAddress + uint16(cpu.V[x]&0xF)*5
+	case 0x30:
+		// FX30 - LD HF, Vx (SCHIP)
+		// Set I = location of the big sprite for digit Vx
+		// The big font is 8x10 pixels and stored after the small font. SCHIP's only contains the digits 0-9.
+		cpu.I = cpu.FontAddress + smallFontSize + uint16(cpu.V[x]&0xF)*bigDigitSize
 	case 0x33:
 		// FX33 - LD B, Vx
 		// Store BCD representation of Vx in memory locations I, I+1, and I+2
 		// The interpreter takes the decimal value of Vx, and places the hundreds digit in memory at location in I,
 		// the tens digit at location I+1, and the ones digit at location I+2.
-		cpu.Memory[cpu.I] = cpu.V[x] / 100
-		cpu.Memory[cpu.I+1] = (cpu.V[x] / 10) % 10
-		cpu.Memory[cpu.I+2] = cpu.V[x] % 10
+		if !cpu.checkIndexed(3) {
+			return
+		}
+		cpu.Write8(cpu.indexed(0), cpu.V[x]/100)
+		cpu.Write8(cpu.indexed(1), (cpu.V[x]/10)%10)
+		cpu.Write8(cpu.indexed(2), cpu.V[x]%10)
+		cpu.recordWrite(cpu.I, 3)
 	case 0x55:
 		// FX55 - LD [I], Vx
 		// Store registers V0 through Vx in memory starting at location I
 		// The interpreter copies the values of registers V0 through Vx into memory, starting at the address in I.
+		if !cpu.checkIndexed(int(x) + 1) {
+			return
+		}
 		for i := uint16(0); i <= x; i++ {
-			cpu.Memory[cpu.I+i] = cpu.V[i]
+			cpu.Write8(cpu.indexed(int(i)), cpu.V[i])
 		}
-		// Original CHIP-8 behavior increments I
-		cpu.I += x + 1
+		cpu.recordWrite(cpu.I, x+1)
+		cpu.incrementIndex(x)
 	case 0x65:
 		// FX65 - LD Vx, [I]
 		// Read registers V0 through Vx from memory starting at location I
 		// The interpreter reads values from memory starting at location I into registers V0 through Vx.
+		if !cpu.checkIndexed(int(x) + 1) {
+			return
+		}
 		for i := uint16(0); i <= x; i++ {
-			cpu.V[i] = cpu.Memory[cpu.I+i]
+			cpu.V[i] = cpu.Read8(cpu.indexed(int(i)))
 		}
-		// Original CHIP-8 behavior increments I
-		cpu.I += x + 1
+		cpu.recordRead(cpu.I, x+1)
+		cpu.incrementIndex(x)
+	case 0x75:
+		// FX75 - LD R, Vx (SCHIP)
+		// Store registers V0 through Vx in the RPL user flags
+		// SCHIP has 8 flags and XO-CHIP 16; registers beyond the last flag are ignored.
+		n := min(int(x)+1, cpu.Platform.FlagCount())
+		copy(cpu.Flags[:n], cpu.V[:n])
+	case 0x85:
+		// FX85 - LD Vx, R (SCHIP)
+		// Read registers V0 through Vx from the RPL user flags
+		n := min(int(x)+1, cpu.Platform.FlagCount())
+		copy(cpu.V[:n], cpu.Flags[:n])
 	default:
 		// Unknown opcode
 		fmt.Printf("Unknown 0xF opcode: 0x%02X\n", sel)
 	}
 }
+
+// waitForKey advances the FX0A state machine: it waits for a key to be
+// pressed, then for the same key to be released. It returns true once the key
+// in KeyWait has been released.
+func (cpu *CPU) waitForKey() bool {
+	wait := &cpu.KeyWait
+	if !wait.Pressed {
+		for key, pressed := range cpu.Keys {
+			if pressed {
+				wait.Pressed, wait.Key = true, uint8(key)
+				break
+			}
+		}
+		return false
+	}
+	return !cpu.Keys[wait.Key]
+}
+
+// incrementIndex advances I after FX55/FX65 according to the memory quirks.
+// Original CHIP-8 behavior increments I by X+1.
+func (cpu *CPU) incrementIndex(x uint16) {
+	switch {
+	case cpu.Quirks.MemoryLeaveIUnchanged:
+	case cpu.Quirks.MemoryIncrementByX:
+		cpu.I += x
+	default:
+		cpu.I += x + 1
+	}
+}
@@ -0,0 +1,77 @@
+package cpu
+
+import "testing"
+
+// keyWaitProgram waits for a key with FX0A and then loops:
+//
+//	200: F50A  LD V5, K
+//	202: 1202  JP 202
+var keyWaitProgram = []uint16{0xF50A, 0x1202}
+
+func TestWaitForKey(t *testing.T) {
+	cpu := newTestCPU(t, keyWaitProgram...)
+	cpu.V[5] = 0xFF
+
+	// No key: FX0A runs again and again
+	cpu.Run(10)
+	if cpu.PC != 0x200 || cpu.KeyWait != (KeyWait{}) {
+		t.Fatalf("without a key PC = %03X, KeyWait = %+v; want 200, zero", cpu.PC, cpu.KeyWait)
+	}
+
+	// A pressed key is remembered, but FX0A waits for its release
+	cpu.SetKey(0x7, true)
+	cpu.Run(10)
+	if cpu.PC != 0x200 || cpu.KeyWait != (KeyWait{Pressed: true, Key: 0x7}) {
+		t.Fatalf("with key 7 held PC = %03X, KeyWait = %+v; want 200, key 7 pressed", cpu.PC, cpu.KeyWait)
+	}
+
+	// Other keys pressed meanwhile don't replace it
+	cpu.SetKey(0x2, true)
+	cpu.SetKey(0x7, false)
+	cpu.Run(1)
+	if cpu.PC != 0x202 || cpu.V[5] != 0x7 {
+		t.Fatalf("after releasing key 7 PC = %03X, V5 = %X; want 202, 7", cpu.PC, cpu.V[5])
+	}
+	if cpu.KeyWait != (KeyWait{}) {
+		t.Errorf("KeyWait = %+v after FX0A, want zero", cpu.KeyWait)
+	}
+}
+
+func TestWaitForKeyHeldBefore(t *testing.T) {
+	// A key held when FX0A starts counts once it is released
+	cpu := newTestCPU(t, keyWaitProgram...)
+	cpu.SetKey(0xC, true)
+	cpu.Run(10)
+	if cpu.PC != 0x200 {
+		t.Fatalf("PC = %03X with the key still held, want 200", cpu.PC)
+	}
+	cpu.SetKey(0xC, false)
+	cpu.Run(1)
+	if cpu.PC != 0x202 || cpu.V[5] != 0xC {
+		t.Fatalf("PC = %03X, V5 = %X after the release; want 202, C", cpu.PC, cpu.V[5])
+	}
+}
+
+func TestWaitForKeySaveState(t *testing.T) {
+	// A state saved between the press and the release finishes the wait
+	cpu := newTestCPU(t, keyWaitProgram...)
+	cpu.SetKey(0x4, true)
+	cpu.Run(3)
+	data, err := cpu.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewCPU()
+	if err := restored.LoadState(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.KeyWait != (KeyWait{Pressed: true, Key: 0x4}) {
+		t.Fatalf("restored KeyWait = %+v, want key 4 pressed", restored.KeyWait)
+	}
+	// The keypad isn't saved, so the key is released in the restored CPU
+	restored.Run(1)
+	if restored.PC != 0x202 || restored.V[5] != 0x4 {
+		t.Fatalf("PC = %03X, V5 = %X after loading; want 202, 4", restored.PC, restored.V[5])
+	}
+}
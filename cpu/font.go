@@ -0,0 +1,222 @@
+package cpu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Size of the hexadecimal font: 16 digits of 5 bytes
+const smallFontSize = 16 * 5
+
+// Size of a digit of the big font
+const bigDigitSize = 10
+
+// Font is a set of digit sprites in the interpreter area.
+// FX29 points I at the small digits, FX30 at the big digits, which are
+// stored right after the small ones.
+type Font struct {
+	Name  string
+	Small []byte // Hexadecimal digits 0-F, 4x5 pixels each
+	Big   []byte // Big digits, 8x10 pixels each; SCHIP only has 0-9
+}
+
+// COSMAC VIP CHIP-8 font
+var vipFont = []byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x60, 0x20, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0xA0, 0xA0, 0xF0, 0x20, 0x20, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x10, 0x10, 0x10, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xF0, 0x50, 0x70, 0x50, 0xF0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xF0, 0x50, 0x50, 0x50, 0xF0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+// DREAM 6800 CHIPOS font
+var dream6800Font = []byte{
+	0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+	0x40, 0x40, 0x40, 0x40, 0x40, // 1
+	0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+	0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+	0x80, 0xA0, 0xA0, 0xE0, 0x20, // 4
+	0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+	0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+	0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+	0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+	0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+	0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+	0xC0, 0xA0, 0xE0, 0xA0, 0xC0, // B
+	0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+	0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+	0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+	0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+}
+
+// ETI-660 font
+var eti660Font = []byte{
+	0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+	0x20, 0x20, 0x20, 0x20, 0x20, // 1
+	0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+	0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+	0xA0, 0xA0, 0xE0, 0x20, 0x20, // 4
+	0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+	0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+	0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+	0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+	0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+	0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+	0x80, 0x80, 0xE0, 0xA0, 0xE0, // B
+	0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+	0x20, 0x20, 0xE0, 0xA0, 0xE0, // D
+	0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+	0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+}
+
+// SUPER-CHIP font, also used by Octo
+var schipFont = []byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x20, 0x60, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+// SUPER-CHIP big font for decimal digits 0-9
+var schipBigFont = []byte{
+	0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, // 0
+	0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, // 1
+	0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, // 2
+	0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, // 3
+	0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, // 4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, // 5
+	0x3E, 0x7C, 0xE0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, // 6
+	0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, // 7
+	0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, // 8
+	0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, // 9
+}
+
+// Octo big font for hexadecimal digits 0-F
+var octoBigFont = []byte{
+	0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, // 0
+	0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, // 1
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // 2
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 3
+	0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, // 4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 5
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 6
+	0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, // 7
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 8
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 9
+	0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+	0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+	0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+	0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+}
+
+// Built-in fonts, by the names accepted by ParseFont.
+// The fonts of interpreters without a big font get the SCHIP one.
+var fonts = map[string]Font{
+	"vip":       {Name: "vip", Small: vipFont, Big: schipBigFont},
+	"dream6800": {Name: "dream6800", Small: dream6800Font, Big: schipBigFont},
+	"eti660":    {Name: "eti660", Small: eti660Font, Big: schipBigFont},
+	"schip":     {Name: "schip", Small: schipFont, Big: schipBigFont},
+	"octo":      {Name: "octo", Small: schipFont, Big: octoBigFont},
+}
+
+// FontNames returns the names of the built-in fonts, sorted.
+func FontNames() []string {
+	names := make([]string, 0, len(fonts))
+	for name := range fonts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseFont returns the built-in font with the given name.
+func ParseFont(name string) (Font, error) {
+	if f, ok := fonts[name]; ok {
+		return f, nil
+	}
+	return Font{}, fmt.Errorf("unknown font %q (expected %s)", name, strings.Join(FontNames(), ", "))
+}
+
+// ReadFont reads a custom font: the 80 bytes of the hexadecimal digits 0-F,
+// optionally followed by up to 16 big digits of 10 bytes each. A font without
+// big digits gets the SCHIP ones.
+func ReadFont(name string, data []byte) (Font, error) {
+	big := len(data) - smallFontSize
+	if big < 0 || big%bigDigitSize != 0 || big > 16*bigDigitSize {
+		return Font{}, fmt.Errorf("%s: a font is %d bytes, optionally followed by up to 16 big digits of %d bytes (got %d bytes)",
+			name, smallFontSize, bigDigitSize, len(data))
+	}
+	f := Font{Name: name, Small: data[:smallFontSize], Big: data[smallFontSize:]}
+	if len(f.Big) == 0 {
+		f.Big = schipBigFont
+	}
+	return f, nil
+}
+
+// Font returns the platform's font.
+func (p Platform) Font() Font {
+	switch p {
+	case PlatformSCHIP:
+		return fonts["schip"]
+	case PlatformXOCHIP:
+		return fonts["octo"]
+	case PlatformETI660:
+		return fonts["eti660"]
+	case PlatformDREAM6800:
+		return fonts["dream6800"]
+	default:
+		return fonts["vip"]
+	}
+}
+
+// Font returns the font loaded with SetFont.
+func (cpu *CPU) Font() Font {
+	return cpu.font
+}
+
+// SetFont loads a font into the interpreter area at address, with the big
+// digits right after the small ones, and points FX29 and FX30 at it.
+// The interpreter area ends at the platform's load address.
+func (cpu *CPU) SetFont(font Font, address uint16) error {
+	if len(font.Small) != smallFontSize {
+		return fmt.Errorf("font %s has %d bytes of small digits instead of %d", font.Name, len(font.Small), smallFontSize)
+	}
+	if int(address)+len(font.Small)+len(font.Big) > int(cpu.Platform.Descriptor().LoadAddress) {
+		return fmt.Errorf("font %s doesn't fit in the interpreter area at %03X", font.Name, address)
+	}
+	for i, b := range font.Small {
+		cpu.Write8(address+uint16(i), b)
+	}
+	for i, b := range font.Big {
+		cpu.Write8(address+smallFontSize+uint16(i), b)
+	}
+	cpu.font = font
+	cpu.FontAddress = address
+	return nil
+}
@@ -0,0 +1,75 @@
+package cpu
+
+import "fmt"
+
+// MemoryPolicy selects what the instructions that access memory through I
+// (DXYN, FX33, FX55 and FX65) do with addresses past the end of memory.
+// I can point there after FX1E or FX55/FX65.
+type MemoryPolicy int
+
+const (
+	MemoryWrap  MemoryPolicy = iota // Addresses wrap around to the start of memory
+	MemoryClamp                     // Addresses past the end access the last byte of memory
+	MemoryFault                     // The instruction raises a Fault and the CPU stops
+)
+
+// Names accepted by ParseMemoryPolicy
+var memoryPolicyNames = map[string]MemoryPolicy{
+	"wrap":  MemoryWrap,
+	"clamp": MemoryClamp,
+	"fault": MemoryFault,
+}
+
+// ParseMemoryPolicy converts a policy name (wrap, clamp or fault) into a MemoryPolicy.
+func ParseMemoryPolicy(name string) (MemoryPolicy, error) {
+	if p, ok := memoryPolicyNames[name]; ok {
+		return p, nil
+	}
+	return MemoryWrap, fmt.Errorf("unknown memory policy %q (expected wrap, clamp or fault)", name)
+}
+
+// Fault is an access past the end of memory with the MemoryFault policy.
+// The CPU stops at the faulting instruction; see CPU.Fault.
+type Fault struct {
+	PC      uint16 // Address of the faulting instruction
+	Opcode  uint16 // The faulting instruction
+	I       uint16 // Index register when the instruction ran
+	Address int    // First address out of bounds
+}
+
+// Error describes the fault with the instruction that caused it.
+func (f *Fault) Error() string {
+	return fmt.Sprintf("%04X at %03X accessed memory out of bounds at %X (I = %03X)", f.Opcode, f.PC, f.Address, f.I)
+}
+
+// checkIndexed reports whether the instruction may access the n bytes at I.
+// With the MemoryFault policy, an access past the end of memory raises a
+// fault instead, and the instruction must not run.
+func (cpu *CPU) checkIndexed(n int) bool {
+	end := int(cpu.I) + n
+	if cpu.MemoryPolicy != MemoryFault || end <= cpu.memorySize() {
+		return true
+	}
+	cpu.Fault = &Fault{
+		PC:      cpu.PC,
+		Opcode:  cpu.CurrentOpcode,
+		I:       cpu.I,
+		Address: max(int(cpu.I), cpu.memorySize()),
+	}
+	return false
+}
+
+// indexed returns the address of the byte at offset from I, wrapped or
+// clamped to memory according to the memory policy.
+func (cpu *CPU) indexed(offset int) uint16 {
+	address := int(cpu.I) + offset
+	size := cpu.memorySize()
+	switch {
+	case address < size:
+		return uint16(address)
+	case cpu.MemoryPolicy == MemoryClamp:
+		return uint16(size - 1)
+	default:
+		return uint16(address % size)
+	}
+}
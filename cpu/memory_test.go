@@ -0,0 +1,133 @@
+package cpu
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestMemoryPolicy runs the instructions that access memory through I with
+// I near the end of cpu.Memory, without a bus.
+func TestMemoryPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode uint16
+		v      []byte // V0, V1, ... before the instruction
+		wrap   func(t *testing.T, cpu *CPU)
+		clamp  func(t *testing.T, cpu *CPU)
+	}{
+		{
+			name: "FX33", opcode: 0xF033, v: []byte{123},
+			wrap: func(t *testing.T, cpu *CPU) {
+				wantMemory(t, cpu, map[uint16]byte{0xFFE: 1, 0xFFF: 2, 0x000: 3})
+			},
+			clamp: func(t *testing.T, cpu *CPU) {
+				wantMemory(t, cpu, map[uint16]byte{0xFFE: 1, 0xFFF: 3, 0x000: 0xCC})
+			},
+		},
+		{
+			name: "FX55", opcode: 0xF255, v: []byte{0x11, 0x22, 0x33},
+			wrap: func(t *testing.T, cpu *CPU) {
+				wantMemory(t, cpu, map[uint16]byte{0xFFE: 0x11, 0xFFF: 0x22, 0x000: 0x33})
+			},
+			clamp: func(t *testing.T, cpu *CPU) {
+				wantMemory(t, cpu, map[uint16]byte{0xFFE: 0x11, 0xFFF: 0x33, 0x000: 0xCC})
+			},
+		},
+		{
+			name: "FX65", opcode: 0xF265, v: []byte{1, 2, 3},
+			wrap: func(t *testing.T, cpu *CPU) {
+				wantRegisters(t, cpu, 0xAA, 0xBB, 0xCC)
+			},
+			clamp: func(t *testing.T, cpu *CPU) {
+				wantRegisters(t, cpu, 0xAA, 0xBB, 0xBB)
+			},
+		},
+		{
+			name: "DXYN", opcode: 0xD013, v: []byte{0, 0},
+			wrap: func(t *testing.T, cpu *CPU) {
+				// Rows from FFE, FFF and 000
+				wantLit(t, cpu, [2]int{0, 0}, [2]int{1, 0}, [2]int{2, 1},
+					[2]int{0, 2}, [2]int{1, 2}, [2]int{4, 2}, [2]int{5, 2})
+			},
+			clamp: func(t *testing.T, cpu *CPU) {
+				// Rows from FFE, FFF and FFF again
+				wantLit(t, cpu, [2]int{0, 0}, [2]int{1, 0}, [2]int{2, 1}, [2]int{2, 2})
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, policy := range []MemoryPolicy{MemoryWrap, MemoryClamp, MemoryFault} {
+			t.Run(tt.name+"/"+[...]string{"wrap", "clamp", "fault"}[policy], func(t *testing.T) {
+				cpu := newTestCPU(t, 0xAFFE, tt.opcode) // LD I, FFE
+				cpu.MemoryPolicy = policy
+				copy(cpu.V[:], tt.v)
+				cpu.Memory[0xFFE], cpu.Memory[0xFFF], cpu.Memory[0x000] = 0xC0, 0x20, 0xCC
+				if tt.name == "FX65" {
+					cpu.Memory[0xFFE], cpu.Memory[0xFFF] = 0xAA, 0xBB
+				}
+				cpu.Step()
+				memory, v, display := cpu.Memory, cpu.V, cpu.Display
+				cpu.Step()
+
+				switch policy {
+				case MemoryWrap:
+					tt.wrap(t, cpu)
+				case MemoryClamp:
+					tt.clamp(t, cpu)
+				case MemoryFault:
+					want := &Fault{PC: 0x202, Opcode: tt.opcode, I: 0xFFE, Address: 0x1000}
+					if !reflect.DeepEqual(cpu.Fault, want) {
+						t.Errorf("Fault = %+v, want %+v", cpu.Fault, want)
+					}
+					if cpu.Memory != memory || cpu.V != v || cpu.Display != display {
+						t.Error("the faulting instruction changed memory, registers or the display")
+					}
+					if cpu.I != 0xFFE {
+						t.Errorf("I = %03X, want FFE", cpu.I)
+					}
+				}
+				if policy != MemoryFault && cpu.Fault != nil {
+					t.Errorf("Fault = %v, want none", cpu.Fault)
+				}
+			})
+		}
+	}
+}
+
+// TestMemoryPolicyInBounds checks that an access ending at the last byte of memory doesn't fault.
+func TestMemoryPolicyInBounds(t *testing.T) {
+	cpu := newTestCPU(t, 0xAFFD, 0xF255) // LD I, FFD; LD [I], V0-V2
+	cpu.MemoryPolicy = MemoryFault
+	cpu.V[2] = 0x42
+	cpu.Run(2)
+	if cpu.Fault != nil || cpu.Memory[0xFFF] != 0x42 {
+		t.Errorf("Fault = %v, [FFF] = %02X; want no fault and 42", cpu.Fault, cpu.Memory[0xFFF])
+	}
+}
+
+func wantMemory(t *testing.T, cpu *CPU, want map[uint16]byte) {
+	t.Helper()
+	for address, b := range want {
+		if cpu.Memory[address] != b {
+			t.Errorf("[%03X] = %02X, want %02X", address, cpu.Memory[address], b)
+		}
+	}
+}
+
+func wantRegisters(t *testing.T, cpu *CPU, want ...byte) {
+	t.Helper()
+	if got := cpu.V[:len(want)]; !reflect.DeepEqual(got, want) {
+		t.Errorf("V0-V%d = % X, want % X", len(want)-1, got, want)
+	}
+}
+
+func wantLit(t *testing.T, cpu *CPU, pixels ...[2]int) {
+	t.Helper()
+	want := make(map[[2]int]bool)
+	for _, p := range pixels {
+		want[p] = true
+	}
+	if got := litPixels(cpu); !reflect.DeepEqual(got, want) {
+		t.Errorf("lit pixels = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package cpu
+
+import "fmt"
+
+// Descriptor describes the machine a platform's programs run on.
+type Descriptor struct {
+	LoadAddress uint16     // Where programs are loaded; everything below is the interpreter area
+	FontAddress uint16     // Where SetPlatform loads the font, in the interpreter area
+	Width       int        // Display width in pixels
+	Height      int        // Display height in pixels
+	Hires       bool       // Has the SCHIP 128x64 display mode (00FE/00FF)
+	Extensions  Extensions // Instructions the platform adds to CHIP-8
+}
+
+// Extensions are the instructions a platform adds to CHIP-8, besides the SCHIP
+// instructions, which are available on every platform with the hires mode.
+type Extensions struct {
+	HiresCHIP8 bool // 0230 clears the display, and programs starting with 1260 start at 2C0
+}
+
+// Entry point of hires CHIP-8 programs. They start with a 1260 jump into the
+// machine code that switches the VIP to 64x64 pixels, which then runs the
+// program at 2C0.
+const hiresEntry = 0x2C0
+
+// Descriptors of the platforms, by Platform.
+// The VIP, HP48 and DREAM 6800 kept their fonts in ROM, outside the memory
+// programs see, so those platforms load them at 0x050 like most modern
+// interpreters; Octo loads the XO-CHIP fonts at 0x000.
+var descriptors = [...]Descriptor{
+	PlatformCHIP8:       {LoadAddress: 0x200, FontAddress: 0x050, Width: 64, Height: 32, Hires: true},
+	PlatformSCHIP:       {LoadAddress: 0x200, FontAddress: 0x050, Width: 64, Height: 32, Hires: true},
+	PlatformXOCHIP:      {LoadAddress: 0x200, FontAddress: 0x000, Width: 64, Height: 32, Hires: true},
+	PlatformCHIP8Hires:  {LoadAddress: 0x200, FontAddress: 0x050, Width: 64, Height: 64, Extensions: Extensions{HiresCHIP8: true}},
+	PlatformETI660:      {LoadAddress: 0x600, FontAddress: 0x050, Width: 64, Height: 48},
+	PlatformDREAM6800:   {LoadAddress: 0x200, FontAddress: 0x050, Width: 64, Height: 32},
+	PlatformModernCHIP8: {LoadAddress: 0x200, FontAddress: 0x050, Width: 64, Height: 32, Hires: true},
+}
+
+// Descriptor returns the machine of the platform.
+// The CHIP-8 platform keeps the SCHIP hires mode, so SCHIP programs that
+// aren't identified as such still run.
+func (p Platform) Descriptor() Descriptor {
+	if p < 0 || int(p) >= len(descriptors) {
+		return descriptors[PlatformCHIP8]
+	}
+	return descriptors[p]
+}
+
+// Entry returns the address a program loaded on the machine starts at.
+func (d Descriptor) Entry(program []byte) uint16 {
+	if d.Extensions.HiresCHIP8 && isHiresCHIP8(program) {
+		return hiresEntry
+	}
+	return d.LoadAddress
+}
+
+// DetectPlatform recognizes the platforms whose programs can be told apart by
+// their contents: hires CHIP-8 programs start with a 1260 jump.
+func DetectPlatform(program []byte) (Platform, bool) {
+	if isHiresCHIP8(program) {
+		return PlatformCHIP8Hires, true
+	}
+	return PlatformCHIP8, false
+}
+
+// isHiresCHIP8 reports whether a program starts with the 1260 jump of hires CHIP-8.
+func isHiresCHIP8(program []byte) bool {
+	return len(program) >= 2 && program[0] == 0x12 && program[1] == 0x60
+}
+
+// memorySize returns the size of the bus if it knows its size, or of Memory.
+// Every platform has 4KB; a Bus gives the CPU memory of another size.
+func (cpu *CPU) memorySize() int {
+	if size := busSize(cpu.Bus); size > 0 {
+		return min(size, 0x10000)
+	}
+	return len(cpu.Memory)
+}
+
+// LoadProgram loads a CHIP-8 program at the platform's load address and points
+// PC at its entry point. Set the platform with SetPlatform first.
+// Parameters:
+//   - program: The byte slice containing the CHIP-8 program to load
+func (cpu *CPU) LoadProgram(program []byte) error {
+	d := cpu.Platform.Descriptor()
+	if size := cpu.memorySize() - int(d.LoadAddress); len(program) > size {
+		return fmt.Errorf("ROM is too large for %s (%d bytes, at most %d)", cpu.Platform, len(program), size)
+	}
+	for i := range program {
+		cpu.Write8(d.LoadAddress+uint16(i), program[i])
+	}
+	cpu.PC = d.Entry(program)
+	return nil
+}
@@ -0,0 +1,150 @@
+package cpu
+
+import "testing"
+
+// A hires CHIP-8 program: the 1260 jump into the VIP's 64x64 machine code
+var hiresProgram = assemble(0x1260, 0x00E0)
+
+func TestLoadProgram(t *testing.T) {
+	program := assemble(0x6001, 0x1202)
+	tests := []struct {
+		platform    Platform
+		program     []byte
+		loadAddress uint16
+		entry       uint16
+	}{
+		{PlatformCHIP8, program, 0x200, 0x200},
+		{PlatformSCHIP, program, 0x200, 0x200},
+		{PlatformXOCHIP, program, 0x200, 0x200},
+		{PlatformCHIP8Hires, program, 0x200, 0x200},
+		{PlatformCHIP8Hires, hiresProgram, 0x200, 0x2C0},
+		{PlatformCHIP8, hiresProgram, 0x200, 0x200}, // Only the hires platform has the 1260 entry
+		{PlatformETI660, program, 0x600, 0x600},
+		{PlatformDREAM6800, program, 0x200, 0x200},
+		{PlatformModernCHIP8, program, 0x200, 0x200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform.String(), func(t *testing.T) {
+			cpu := NewCPU()
+			cpu.SetPlatform(tt.platform)
+			if err := cpu.LoadProgram(tt.program); err != nil {
+				t.Fatal(err)
+			}
+			if cpu.PC != tt.entry {
+				t.Errorf("PC = %03X, want %03X", cpu.PC, tt.entry)
+			}
+			if got := tt.platform.Descriptor().Entry(tt.program); got != tt.entry {
+				t.Errorf("Entry = %03X, want %03X", got, tt.entry)
+			}
+			for i, b := range tt.program {
+				if got := cpu.Memory[int(tt.loadAddress)+i]; got != b {
+					t.Fatalf("[%03X] = %02X, want %02X", int(tt.loadAddress)+i, got, b)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadProgramTooLarge(t *testing.T) {
+	tests := []struct {
+		platform Platform
+		max      int
+	}{
+		{PlatformCHIP8, 4096 - 0x200},
+		{PlatformETI660, 4096 - 0x600},
+	}
+	for _, tt := range tests {
+		cpu := NewCPU()
+		cpu.SetPlatform(tt.platform)
+		if err := cpu.LoadProgram(make([]byte, tt.max)); err != nil {
+			t.Errorf("%s: a ROM of %d bytes doesn't fit: %v", tt.platform, tt.max, err)
+		}
+		if err := cpu.LoadProgram(make([]byte, tt.max+1)); err == nil {
+			t.Errorf("%s: a ROM of %d bytes fits", tt.platform, tt.max+1)
+		}
+	}
+}
+
+func TestDisplaySize(t *testing.T) {
+	tests := []struct {
+		platform                Platform
+		width, height           int
+		hiresWidth, hiresHeight int // With 00FF
+	}{
+		{PlatformCHIP8, 64, 32, 128, 64},
+		{PlatformSCHIP, 64, 32, 128, 64},
+		{PlatformXOCHIP, 64, 32, 128, 64},
+		{PlatformCHIP8Hires, 64, 64, 64, 64},
+		{PlatformETI660, 64, 48, 64, 48},
+		{PlatformDREAM6800, 64, 32, 64, 32},
+		{PlatformModernCHIP8, 64, 32, 128, 64},
+	}
+	for _, tt := range tests {
+		cpu := NewCPU()
+		cpu.SetPlatform(tt.platform)
+		if w, h := cpu.DisplaySize(); w != tt.width || h != tt.height {
+			t.Errorf("%s: display is %dx%d, want %dx%d", tt.platform, w, h, tt.width, tt.height)
+		}
+		cpu.Hires = true
+		if w, h := cpu.DisplaySize(); w != tt.hiresWidth || h != tt.hiresHeight {
+			t.Errorf("%s: hires display is %dx%d, want %dx%d", tt.platform, w, h, tt.hiresWidth, tt.hiresHeight)
+		}
+	}
+}
+
+func TestDetectPlatform(t *testing.T) {
+	if p, ok := DetectPlatform(hiresProgram); !ok || p != PlatformCHIP8Hires {
+		t.Errorf("DetectPlatform(1260 ...) = %s, %t; want %s, true", p, ok, PlatformCHIP8Hires)
+	}
+	for _, program := range [][]byte{nil, {0x12}, assemble(0x1262), assemble(0x6001, 0x1260)} {
+		if p, ok := DetectPlatform(program); ok || p != PlatformCHIP8 {
+			t.Errorf("DetectPlatform(% X) = %s, %t; want %s, false", program, p, ok, PlatformCHIP8)
+		}
+	}
+}
+
+func TestDescriptorOutOfRange(t *testing.T) {
+	if d := Platform(99).Descriptor(); d != PlatformCHIP8.Descriptor() {
+		t.Errorf("descriptor of an unknown platform = %+v, want CHIP-8's", d)
+	}
+}
+
+func TestModernCHIP8Quirks(t *testing.T) {
+	// The chip-8-database's CHIP-8 ids: only the VIP ones wait for the vertical blank
+	tests := []struct {
+		id     string
+		vblank bool
+	}{
+		{"originalChip8", true},
+		{"hybridVIP", true},
+		{"modernChip8", false},
+	}
+	for _, tt := range tests {
+		p, err := ParsePlatform(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := p.Quirks()
+		if q.VBlank != tt.vblank || q.Logic != tt.vblank {
+			t.Errorf("%s: %s quirks %+v, want vblank and logic %t", tt.id, p, q, tt.vblank)
+		}
+	}
+}
+
+func TestPlatformFont(t *testing.T) {
+	// A new CPU has the font of its platform, plain CHIP-8, like one set to it
+	cpu := NewCPU()
+	if got := cpu.Font().Name; got != "vip" {
+		t.Errorf("NewCPU font = %s, want vip", got)
+	}
+	for _, p := range []Platform{PlatformCHIP8, PlatformSCHIP, PlatformXOCHIP, PlatformETI660, PlatformModernCHIP8} {
+		cpu.SetPlatform(p)
+		font, address := p.Font(), p.Descriptor().FontAddress
+		if cpu.Font().Name != font.Name || cpu.FontAddress != address {
+			t.Errorf("%s: font %s at %03X, want %s at %03X", p, cpu.Font().Name, cpu.FontAddress, font.Name, address)
+		}
+		if got := cpu.Memory[address : address+5]; string(got) != string(font.Small[:5]) {
+			t.Errorf("%s: digit 0 is % X, want % X", p, got, font.Small[:5])
+		}
+	}
+}
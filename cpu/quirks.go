@@ -0,0 +1,126 @@
+package cpu
+
+import "fmt"
+
+// Platform identifies the CHIP-8 variant a program was written for.
+type Platform int
+
+const (
+	PlatformCHIP8       Platform = iota // Original COSMAC VIP CHIP-8
+	PlatformSCHIP                       // SUPER-CHIP 1.1 (128x64 hires display, scrolling, big font)
+	PlatformXOCHIP                      // XO-CHIP (runs with XO-CHIP quirks, without its extended instructions)
+	PlatformCHIP8Hires                  // COSMAC VIP hires CHIP-8 (64x64 display)
+	PlatformETI660                      // ETI-660 CHIP-8 (programs start at 0x600, 64x48 display)
+	PlatformDREAM6800                   // DREAM 6800 CHIPOS
+	PlatformModernCHIP8                 // CHIP-8 as most modern interpreters run it, without the VIP's quirks
+)
+
+// Names accepted by ParsePlatform, including the platform ids of the chip-8-database project
+var platformNames = map[string]Platform{
+	"chip8":         PlatformCHIP8,
+	"chip-8":        PlatformCHIP8,
+	"originalChip8": PlatformCHIP8,
+	"hybridVIP":     PlatformCHIP8,
+	"modernChip8":   PlatformModernCHIP8,
+	"modernchip8":   PlatformModernCHIP8,
+	"schip":         PlatformSCHIP,
+	"superchip":     PlatformSCHIP,
+	"superchip1":    PlatformSCHIP,
+	"chip48":        PlatformSCHIP,
+	"xochip":        PlatformXOCHIP,
+	"xo-chip":       PlatformXOCHIP,
+	"chip8hires":    PlatformCHIP8Hires,
+	"chip-8-hires":  PlatformCHIP8Hires,
+	"eti660":        PlatformETI660,
+	"eti-660":       PlatformETI660,
+	"dream6800":     PlatformDREAM6800,
+	"dream-6800":    PlatformDREAM6800,
+}
+
+// ParsePlatform converts a platform name into a Platform.
+func ParsePlatform(name string) (Platform, error) {
+	if p, ok := platformNames[name]; ok {
+		return p, nil
+	}
+	return PlatformCHIP8, fmt.Errorf("unknown platform %q", name)
+}
+
+// String returns the display name of the platform.
+func (p Platform) String() string {
+	switch p {
+	case PlatformSCHIP:
+		return "SCHIP"
+	case PlatformXOCHIP:
+		return "XO-CHIP"
+	case PlatformCHIP8Hires:
+		return "CHIP-8 hires"
+	case PlatformETI660:
+		return "ETI-660"
+	case PlatformDREAM6800:
+		return "DREAM 6800"
+	case PlatformModernCHIP8:
+		return "modern CHIP-8"
+	default:
+		return "CHIP-8"
+	}
+}
+
+// Quirks returns the behaviour of the platform's reference interpreter.
+// The interpreters of the ETI-660 and the DREAM 6800 are modelled on the
+// COSMAC VIP's and get its quirks. Modern CHIP-8 keeps the VIP's memory and
+// shift behaviour, but draws without waiting and leaves VF alone in 8XY1-8XY3.
+func (p Platform) Quirks() Quirks {
+	switch p {
+	case PlatformSCHIP:
+		return Quirks{Shift: true, MemoryLeaveIUnchanged: true, Jump: true, CollisionRows: true}
+	case PlatformXOCHIP:
+		return Quirks{Wrap: true}
+	case PlatformModernCHIP8:
+		return Quirks{}
+	default:
+		return Quirks{Logic: true, VBlank: true}
+	}
+}
+
+// Tickrate returns the number of instructions per frame that suits most programs for the platform.
+func (p Platform) Tickrate() int {
+	switch p {
+	case PlatformSCHIP:
+		return 30
+	case PlatformXOCHIP:
+		return 100
+	default:
+		return 15
+	}
+}
+
+// FlagCount returns the number of RPL user flags FX75/FX85 can access.
+func (p Platform) FlagCount() int {
+	if p == PlatformXOCHIP {
+		return 16
+	}
+	return 8
+}
+
+// Quirks selects behaviours that differ between CHIP-8 interpreters.
+// The zero value is the behaviour of the original COSMAC VIP interpreter,
+// except for the VF reset of the logic instructions and the display wait.
+type Quirks struct {
+	Shift                 bool // 8XY6/8XYE shift VX in place instead of shifting VY into VX
+	MemoryIncrementByX    bool // FX55/FX65 increment I by X instead of X+1
+	MemoryLeaveIUnchanged bool // FX55/FX65 leave I unchanged
+	Jump                  bool // BNNN jumps to XNN + VX instead of NNN + V0
+	Logic                 bool // 8XY1/8XY2/8XY3 reset VF to 0
+	VBlank                bool // DXYN waits for the vertical blank before drawing, so at most one sprite is drawn per frame
+	Wrap                  bool // DXYN wraps sprites around the display edges instead of clipping them
+	CollisionRows         bool // DXYN in hires mode sets VF to the number of rows that collided or were clipped (SCHIP 1.1)
+}
+
+// SetPlatform selects the platform, its default quirks and its font,
+// which is loaded at the platform's font address. Set it before LoadProgram,
+// which loads the program at the platform's load address.
+func (cpu *CPU) SetPlatform(p Platform) {
+	cpu.Platform = p
+	cpu.Quirks = p.Quirks()
+	cpu.SetFont(p.Font(), p.Descriptor().FontAddress)
+}
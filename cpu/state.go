@@ -0,0 +1,124 @@
+package cpu
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// stateVersion is incremented when the meaning of a saved field changes.
+// Adding fields doesn't need a new version: gob leaves fields missing from
+// older states at their zero value.
+const stateVersion = 1
+
+// state is the part of the CPU that save states store.
+// The keypad is not saved, since it reflects the player's current input.
+type state struct {
+	Version       int
+	PC            uint16
+	Memory        [4096]byte
+	V             [16]byte
+	Stack         [16]uint16
+	I             uint16
+	SP            uint8
+	DelayTimer    uint8
+	SoundTimer    uint8
+	Display       [128 * 64]byte
+	Hires         bool
+	Exited        bool
+	Platform      Platform
+	Quirks        Quirks
+	Flags         [16]byte
+	WaitingVBlank bool
+	VBlank        bool // The vblank a waiting DXYN waited for has happened
+	KeyWait       KeyWait
+	FontAddress   uint16
+	Font          Font
+	Random        []byte // State of the generator of CXNN's random bytes
+}
+
+// SaveState returns a snapshot of the CPU that LoadState can restore.
+// It holds Memory, so the contents of a Bus set with SetBus are not saved.
+func (cpu *CPU) SaveState() ([]byte, error) {
+	random, err := cpu.random.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	s := state{
+		Version:       stateVersion,
+		PC:            cpu.PC,
+		Memory:        cpu.Memory,
+		V:             cpu.V,
+		Stack:         cpu.Stack,
+		I:             cpu.I,
+		SP:            cpu.SP,
+		DelayTimer:    cpu.DelayTimer,
+		SoundTimer:    cpu.SoundTimer,
+		Display:       cpu.Display,
+		Hires:         cpu.Hires,
+		Exited:        cpu.Exited,
+		Platform:      cpu.Platform,
+		Quirks:        cpu.Quirks,
+		Flags:         cpu.Flags,
+		WaitingVBlank: cpu.WaitingVBlank,
+		VBlank:        cpu.vblank,
+		KeyWait:       cpu.KeyWait,
+		FontAddress:   cpu.FontAddress,
+		Font:          cpu.font,
+		Random:        random,
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadState restores a snapshot taken by SaveState.
+// The CPU is left unchanged if the snapshot can't be decoded. A memory fault
+// is cleared, so loading a state saved before it runs the program again.
+func (cpu *CPU) LoadState(data []byte) error {
+	var s state
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return fmt.Errorf("invalid save state: %w", err)
+	}
+	if s.Version != stateVersion {
+		return fmt.Errorf("unsupported save state version %d", s.Version)
+	}
+	random := cpu.random
+	if len(s.Random) > 0 {
+		// States saved before the generator was keep the current one
+		if err := random.UnmarshalBinary(s.Random); err != nil {
+			return fmt.Errorf("invalid save state: %w", err)
+		}
+	}
+
+	cpu.PC = s.PC
+	cpu.Memory = s.Memory
+	cpu.decoded = nil
+	cpu.V = s.V
+	cpu.Stack = s.Stack
+	cpu.I = s.I
+	cpu.SP = s.SP
+	cpu.DelayTimer = s.DelayTimer
+	cpu.SoundTimer = s.SoundTimer
+	cpu.Display = s.Display
+	cpu.Hires = s.Hires
+	cpu.Exited = s.Exited
+	cpu.Platform = s.Platform
+	cpu.Quirks = s.Quirks
+	cpu.Flags = s.Flags
+	cpu.WaitingVBlank = s.WaitingVBlank
+	cpu.vblank = s.VBlank
+	cpu.KeyWait = s.KeyWait
+	cpu.FontAddress = s.FontAddress
+	cpu.font = s.Font
+	if len(cpu.font.Small) == 0 {
+		// Saved before the font was; Memory still holds the font itself
+		cpu.font = s.Platform.Font()
+	}
+	cpu.random = random
+	cpu.Fault = nil
+	cpu.markAllDirty()
+	return nil
+}
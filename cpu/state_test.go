@@ -0,0 +1,127 @@
+package cpu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSaveLoadState(t *testing.T) {
+	cpu := newTestCPU(t,
+		0x6005, // 200: LD V0, 5
+		0xA300, // 202: LD I, 300
+		0x2208, // 204: CALL 208
+		0x1206, // 206: JP 206
+		0xD015, // 208: DRW V0, V1, 5
+		0x1208, // 20A: JP 20A
+	)
+	cpu.SetPlatform(PlatformXOCHIP)
+	dream, err := ParseFont("dream6800")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cpu.SetFont(dream, 0x100); err != nil {
+		t.Fatal(err)
+	}
+	cpu.Run(5)
+	cpu.DelayTimer, cpu.SoundTimer = 30, 20
+	cpu.Flags[3] = 7
+	cpu.KeyWait = KeyWait{Pressed: true, Key: 0xA}
+
+	data, err := cpu.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewCPU()
+	if err := restored.LoadState(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{
+		"PC", "Memory", "V", "Stack", "I", "SP", "DelayTimer", "SoundTimer", "Display",
+		"Hires", "Platform", "Quirks", "Flags", "KeyWait", "FontAddress",
+	} {
+		got := reflect.ValueOf(restored).Elem().FieldByName(field).Interface()
+		want := reflect.ValueOf(cpu).Elem().FieldByName(field).Interface()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+	if font := restored.Font(); font.Name != "dream6800" {
+		t.Errorf("Font() = %s, want dream6800", font.Name)
+	}
+}
+
+func TestLoadStateResetsDecodeCache(t *testing.T) {
+	saved, err := newTestCPU(t, 0x6002, 0x1202).SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The CPU has decoded LD V0, 1 at 200; the state has LD V0, 2 there
+	cpu := newTestCPU(t, 0x6001, 0x1200)
+	cpu.Run(2)
+	if err := cpu.LoadState(saved); err != nil {
+		t.Fatal(err)
+	}
+	cpu.Step()
+	if cpu.V[0] != 2 {
+		t.Errorf("V0 = %d after loading the state, want 2 from the state's program", cpu.V[0])
+	}
+}
+
+func TestLoadStateClearsFault(t *testing.T) {
+	cpu := newTestCPU(t, 0xAFFF, 0xF155) // LD I, FFF; LD [I], V0-V1
+	cpu.MemoryPolicy = MemoryFault
+	data, err := cpu.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu.Run(2)
+	if cpu.Fault == nil {
+		t.Fatal("F155 at I = FFF didn't fault")
+	}
+
+	// Loading the state saved before the fault runs the program again
+	if err := cpu.LoadState(data); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.Fault != nil || cpu.PC != 0x200 {
+		t.Fatalf("after LoadState: fault %v, PC %03X; want no fault and PC 200", cpu.Fault, cpu.PC)
+	}
+	cpu.Run(2)
+	if cpu.Fault == nil || cpu.Fault.PC != 0x202 {
+		t.Errorf("fault after running again = %v, want one at 202", cpu.Fault)
+	}
+}
+
+func TestLoadStateInvalid(t *testing.T) {
+	cpu := newTestCPU(t, 0x1200)
+	cpu.V[0] = 9
+	if err := cpu.LoadState([]byte("not a state")); err == nil {
+		t.Fatal("LoadState accepted an invalid state")
+	}
+	if cpu.V[0] != 9 || cpu.PC != 0x200 {
+		t.Error("a failed LoadState changed the CPU")
+	}
+}
+
+func TestLoadStateRandom(t *testing.T) {
+	// The random bytes of CXNN continue where they were when the state was saved
+	cpu := newTestCPU(t, 0xC0FF, 0x1200) // 200: RND V0, FF; 202: JP 200
+	cpu.SeedRandom(1)
+	cpu.Run(20)
+	data, err := cpu.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewCPU()
+	if err := restored.LoadState(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		cpu.Run(2)
+		restored.Run(2)
+		if restored.V[0] != cpu.V[0] {
+			t.Fatalf("random byte %d = %02X, want %02X", i, restored.V[0], cpu.V[0])
+		}
+	}
+}
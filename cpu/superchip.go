@@ -0,0 +1,43 @@
+package cpu
+
+// PerformSuperChipOperation handles the SCHIP instructions starting with 0x0,
+// and the instructions starting with 0x0 of the platform's extensions.
+// Parameters:
+//   - instruction: The 16-bit instruction to execute
+func (cpu *CPU) PerformSuperChipOperation(instruction uint16) {
+	switch {
+	case instruction&0xFFF0 == 0x00C0:
+		// 00CN - SCD nibble
+		// Scroll the display down by N pixels
+		cpu.ScrollDown(int(instruction & 0x000F))
+	case instruction == 0x00FB:
+		// 00FB - SCR
+		// Scroll the display right by 4 pixels
+		cpu.ScrollRight(4)
+	case instruction == 0x00FC:
+		// 00FC - SCL
+		// Scroll the display left by 4 pixels
+		cpu.ScrollLeft(4)
+	case instruction == 0x00FD:
+		// 00FD - EXIT
+		// Stop the interpreter
+		cpu.Exited = true
+	case instruction == 0x00FE && cpu.Platform.Descriptor().Hires:
+		// 00FE - LOW
+		// Switch to the 64x32 display mode
+		cpu.Hires = false
+		cpu.ClearScreen()
+	case instruction == 0x00FF && cpu.Platform.Descriptor().Hires:
+		// 00FF - HIGH
+		// Switch to the 128x64 display mode
+		cpu.Hires = true
+		cpu.ClearScreen()
+	case instruction == 0x0230 && cpu.Platform.Descriptor().Extensions.HiresCHIP8:
+		// 0230 - CLS (hires CHIP-8)
+		// Clear the 64x64 display
+		cpu.ClearScreen()
+	default:
+		// 0NNN - SYS addr
+		// Machine code routines of the original interpreter are not supported and are ignored
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"go-r8t/cpu"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// CRTSettings selects which post-processing effects are applied to the framebuffer.
+// All effects are computed on the CPU, so they work on any graphics backend.
+type CRTSettings struct {
+	Persistence bool // Blend each frame with the previous ones to emulate phosphor decay
+	Scanlines   bool // Darken the last row of every scaled pixel
+	PixelGrid   bool // Darken the last column of every scaled pixel
+}
+
+// Phosphor decay applied per frame when persistence is enabled.
+// A pixel that is switched off keeps 60% of its brightness on the next frame,
+// which is enough to hide the flicker caused by XOR sprite redraws.
+const phosphorDecay = 0.6
+
+// Brightness below which a fading pixel is drawn in the background color
+const phosphorCutoff = 1.0 / 256
+
+// Brightness multiplier for scanline and pixel grid rows/columns.
+const crtMaskLevel = 0.55
+
+// Framebuffer converts the CHIP-8 display into an ebiten.Image.
+// The pixels are written in a single WritePixels call per frame instead of
+// drawing one rectangle per lit pixel, and only the CHIP-8 pixels that changed
+// or are still fading out are rendered again.
+type Framebuffer struct {
+	Settings   CRTSettings
+	Foreground color.RGBA // Color of a lit pixel
+	Background color.RGBA // Color of an unlit pixel
+
+	width, height int           // CHIP-8 display size in pixels
+	scale         int           // Number of image pixels per CHIP-8 pixel
+	image         *ebiten.Image // Upscaled display image
+	pixels        []byte        // RGBA buffer uploaded to image every frame
+	phosphor      []float32     // Brightness of each CHIP-8 pixel (0.0 - 1.0)
+	fading        []int         // CHIP-8 pixels that are switched off but still glowing
+	pending       []bool        // CHIP-8 pixels to update in the current frame
+	rendered      renderState   // What pixels was rendered with; the zero value forces a full render
+}
+
+// renderState is the configuration the framebuffer's pixels were rendered with
+type renderState struct {
+	valid      bool
+	settings   CRTSettings
+	foreground color.RGBA
+	background color.RGBA
+}
+
+// NewFramebuffer creates a framebuffer for a width x height display,
+// upscaled by scale in both directions.
+func NewFramebuffer(width, height, scale int) *Framebuffer {
+	return &Framebuffer{
+		Settings:   CRTSettings{Persistence: true},
+		Foreground: color.RGBA{255, 255, 255, 255},
+		Background: color.RGBA{0, 0, 0, 255},
+		width:      width,
+		height:     height,
+		scale:      scale,
+		image:      ebiten.NewImage(width*scale, height*scale),
+		pixels:     make([]byte, width*scale*height*scale*4),
+		phosphor:   make([]float32, width*height),
+		pending:    make([]bool, width*height),
+	}
+}
+
+// Update renders the display memory into the framebuffer image.
+// It should be called exactly once per frame, since persistence decays per call.
+// When the display size changes (e.g. SCHIP hires mode), the pixels are rescaled
+// so that the image keeps its width; its height follows the display's aspect ratio.
+// Parameters:
+//   - display: The display memory, one byte per pixel (1 = lit)
+//   - width, height: The display size in pixels
+//   - dirty: The regions of the display that changed since the last call
+func (fb *Framebuffer) Update(display []byte, width, height int, dirty []cpu.Rect) {
+	if width != fb.width || height != fb.height {
+		fb.scale = fb.width * fb.scale / width
+		fb.width, fb.height = width, height
+		if size := width * fb.scale * height * fb.scale * 4; size != len(fb.pixels) {
+			fb.image = ebiten.NewImage(width*fb.scale, height*fb.scale)
+			fb.pixels = make([]byte, size)
+		}
+		fb.phosphor = make([]float32, width*height)
+		fb.pending = make([]bool, width*height)
+		fb.fading = nil
+		fb.rendered = renderState{}
+	}
+
+	// Collect the pixels whose brightness may change: the changed regions and
+	// the pixels that are still fading out
+	var update []int
+	add := func(i int) {
+		if !fb.pending[i] {
+			fb.pending[i] = true
+			update = append(update, i)
+		}
+	}
+	for _, r := range dirty {
+		for y := r.Y; y < r.Y+r.Height && y < height; y++ {
+			for x := r.X; x < r.X+r.Width && x < width; x++ {
+				add(y*width + x)
+			}
+		}
+	}
+	for _, i := range fb.fading {
+		add(i)
+	}
+
+	// Update their phosphor brightness
+	fb.fading = fb.fading[:0]
+	for _, i := range update {
+		fb.pending[i] = false
+		if i < len(display) && display[i] == 1 {
+			fb.phosphor[i] = 1
+			continue
+		}
+		if fb.Settings.Persistence {
+			fb.phosphor[i] *= phosphorDecay
+		} else {
+			fb.phosphor[i] = 0
+		}
+		if fb.phosphor[i] < phosphorCutoff {
+			fb.phosphor[i] = 0
+		} else {
+			fb.fading = append(fb.fading, i)
+		}
+	}
+
+	// Render the updated pixels, or all of them when the colors or effects changed
+	state := renderState{true, fb.Settings, fb.Foreground, fb.Background}
+	if state != fb.rendered {
+		fb.rendered = state
+		for i := range fb.phosphor {
+			fb.renderPixel(i%width, i/width)
+		}
+	} else if len(update) > 0 {
+		for _, i := range update {
+			fb.renderPixel(i%width, i/width)
+		}
+	} else {
+		return
+	}
+
+	fb.image.WritePixels(fb.pixels)
+}
+
+// renderPixel expands a CHIP-8 pixel into a scale x scale block of the image.
+func (fb *Framebuffer) renderPixel(x, y int) {
+	stride := fb.width * fb.scale * 4
+	base := fb.blend(fb.phosphor[y*fb.width+x])
+	for sy := 0; sy < fb.scale; sy++ {
+		for sx := 0; sx < fb.scale; sx++ {
+			c := base
+			if fb.Settings.Scanlines && sy == fb.scale-1 {
+				c = dim(c, crtMaskLevel)
+			}
+			if fb.Settings.PixelGrid && sx == fb.scale-1 {
+				c = dim(c, crtMaskLevel)
+			}
+
+			offset := (y*fb.scale+sy)*stride + (x*fb.scale+sx)*4
+			fb.pixels[offset] = c.R
+			fb.pixels[offset+1] = c.G
+			fb.pixels[offset+2] = c.B
+			fb.pixels[offset+3] = c.A
+		}
+	}
+}
+
+// Image returns the rendered framebuffer image.
+func (fb *Framebuffer) Image() *ebiten.Image {
+	return fb.image
+}
+
+// blend mixes the background and foreground colors by the given brightness.
+func (fb *Framebuffer) blend(brightness float32) color.RGBA {
+	mix := func(bg, fg uint8) uint8 {
+		return uint8(float32(bg) + (float32(fg)-float32(bg))*brightness)
+	}
+	return color.RGBA{
+		R: mix(fb.Background.R, fb.Foreground.R),
+		G: mix(fb.Background.G, fb.Foreground.G),
+		B: mix(fb.Background.B, fb.Foreground.B),
+		A: 255,
+	}
+}
+
+// dim scales the color channels of c by level.
+func dim(c color.RGBA, level float32) color.RGBA {
+	return color.RGBA{
+		R: uint8(float32(c.R) * level),
+		G: uint8(float32(c.G) * level),
+		B: uint8(float32(c.B) * level),
+		A: c.A,
+	}
+}
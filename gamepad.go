@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"go-r8t/keymap"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Number of frames the binding overlay stays visible after a gamepad is connected
+const gamepadOverlayFrames = 180
+
+// Names of the standard layout gamepad buttons
+var standardButtonNames = map[ebiten.StandardGamepadButton]string{
+	ebiten.StandardGamepadButtonRightBottom:      "a",
+	ebiten.StandardGamepadButtonRightRight:       "b",
+	ebiten.StandardGamepadButtonRightLeft:        "x",
+	ebiten.StandardGamepadButtonRightTop:         "y",
+	ebiten.StandardGamepadButtonFrontTopLeft:     "l1",
+	ebiten.StandardGamepadButtonFrontTopRight:    "r1",
+	ebiten.StandardGamepadButtonFrontBottomLeft:  "l2",
+	ebiten.StandardGamepadButtonFrontBottomRight: "r2",
+	ebiten.StandardGamepadButtonCenterLeft:       "select",
+	ebiten.StandardGamepadButtonCenterRight:      "start",
+	ebiten.StandardGamepadButtonLeftStick:        "l3",
+	ebiten.StandardGamepadButtonRightStick:       "r3",
+	ebiten.StandardGamepadButtonLeftTop:          "up",
+	ebiten.StandardGamepadButtonLeftBottom:       "down",
+	ebiten.StandardGamepadButtonLeftLeft:         "left",
+	ebiten.StandardGamepadButtonLeftRight:        "right",
+	ebiten.StandardGamepadButtonCenterCenter:     "home",
+}
+
+// Gamepads tracks the connected gamepads in the GUI frontend
+type Gamepads struct {
+	ids          []ebiten.GamepadID
+	overlay      bool // Whether the binding overlay is toggled on
+	overlayTimer int  // Frames left to show the overlay after a hotplug event
+}
+
+// Update handles gamepads being connected and disconnected.
+// It must be called once per frame.
+func (gp *Gamepads) Update() {
+	for _, id := range inpututil.AppendJustConnectedGamepadIDs(nil) {
+		gp.ids = append(gp.ids, id)
+		gp.overlayTimer = gamepadOverlayFrames
+		SetStatus("Gamepad connected: " + ebiten.GamepadName(id))
+	}
+
+	connected := gp.ids[:0]
+	for _, id := range gp.ids {
+		if inpututil.IsGamepadJustDisconnected(id) {
+			SetStatus("Gamepad disconnected: " + ebiten.GamepadName(id))
+			continue
+		}
+		connected = append(connected, id)
+	}
+	gp.ids = connected
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+		gp.overlay = !gp.overlay
+	}
+	if gp.overlayTimer > 0 {
+		gp.overlayTimer--
+	}
+}
+
+// Keys returns which CHIP-8 keys are held down on any connected gamepad
+func (gp *Gamepads) Keys() [16]bool {
+	var keys [16]bool
+	for _, id := range gp.ids {
+		padKeys := activeGamepad.Keys(gamepadState(id))
+		for key := range keys {
+			keys[key] = keys[key] || padKeys[key]
+		}
+	}
+	return keys
+}
+
+// Draw shows the gamepad bindings while the overlay is visible
+func (gp *Gamepads) Draw(screen *ebiten.Image) {
+	if !gp.overlay && gp.overlayTimer == 0 {
+		return
+	}
+
+	var lines []string
+	for _, id := range gp.ids {
+		lines = append(lines, "Gamepad: "+ebiten.GamepadName(id))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No gamepad connected")
+	}
+	for _, binding := range strings.Fields(activeGamepad.String()) {
+		lines = append(lines, "  "+binding)
+	}
+	ebitenutil.DebugPrintAt(screen, strings.Join(lines, "\n"), 0, 16)
+}
+
+// gamepadState reads the buttons of a gamepad by keymap button name
+func gamepadState(id ebiten.GamepadID) keymap.GamepadState {
+	state := make(keymap.GamepadState)
+
+	if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+		// Without a known mapping, buttons can only be bound by number
+		for button := ebiten.GamepadButton(0); int(button) < ebiten.GamepadButtonCount(id); button++ {
+			if ebiten.IsGamepadButtonPressed(id, button) {
+				state[fmt.Sprintf("button%d", button)] = true
+			}
+		}
+		return state
+	}
+
+	for button, name := range standardButtonNames {
+		if ebiten.IsStandardGamepadButtonPressed(id, button) {
+			state[name] = true
+		}
+	}
+	state.SetStick(
+		ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal),
+		ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical),
+	)
+	return state
+}
@@ -0,0 +1,211 @@
+// Package inputlog records the keypad input of a session and plays it back.
+//
+// An input log is a text file with one key change per line: the frame at
+// whose start the key changed, the key (0-F) and its new state, e.g.
+//
+//	120 5 down
+//	124 5 up
+//
+// A log can start with the seed of the random bytes of CXNN (see
+// cpu.CPU.SeedRandom), e.g.
+//
+//	seed 9004212342851430537
+//
+// Blank lines and lines starting with # are ignored. Frames are counted by
+// the CPU (see cpu.CPU.Frame), so with the seed a log replays the same input
+// at the same point of the program on every run.
+package inputlog
+
+import (
+	"bufio"
+	"fmt"
+	"go-r8t/cpu"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Event is a change of a key's state at the start of a frame.
+type Event struct {
+	Frame   uint64
+	Key     uint8
+	Pressed bool
+}
+
+// String formats the event as a line of an input log.
+func (e Event) String() string {
+	state := "up"
+	if e.Pressed {
+		state = "down"
+	}
+	return fmt.Sprintf("%d %X %s", e.Frame, e.Key, state)
+}
+
+// parseEvent parses a line written by Event.String.
+func parseEvent(line string) (Event, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return Event{}, fmt.Errorf("expected frame, key and state, got %q", line)
+	}
+	frame, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid frame %q", fields[0])
+	}
+	key, err := strconv.ParseUint(fields[1], 16, 4)
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid key %q", fields[1])
+	}
+	var pressed bool
+	switch fields[2] {
+	case "down":
+		pressed = true
+	case "up":
+	default:
+		return Event{}, fmt.Errorf("invalid key state %q (expected down or up)", fields[2])
+	}
+	return Event{Frame: frame, Key: uint8(key), Pressed: pressed}, nil
+}
+
+// parseSeed parses the seed line of a log. It returns false if the line isn't one.
+func parseSeed(line string) (uint64, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "seed" {
+		return 0, false, nil
+	}
+	if len(fields) != 2 {
+		return 0, true, fmt.Errorf("expected seed, got %q", line)
+	}
+	seed, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, true, fmt.Errorf("invalid seed %q", fields[1])
+	}
+	return seed, true, nil
+}
+
+// Recorder records the changes of the keypad, frame by frame.
+type Recorder struct {
+	seed   uint64
+	events []Event
+	keys   [16]bool
+}
+
+// NewRecorder creates a recorder that starts with all keys released, for a
+// session whose CPU was seeded with seed.
+func NewRecorder(seed uint64) *Recorder {
+	return &Recorder{seed: seed}
+}
+
+// Record records the keys that changed since the last call.
+// It is called at the start of every frame, after the frontend set the keys.
+func (r *Recorder) Record(chip8 *cpu.CPU) {
+	for key, pressed := range chip8.Keys {
+		if pressed != r.keys[key] {
+			r.events = append(r.events, Event{Frame: chip8.Frame, Key: uint8(key), Pressed: pressed})
+			r.keys[key] = pressed
+		}
+	}
+}
+
+// Write writes the recorded events as an input log.
+func (r *Recorder) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "seed", r.seed)
+	fmt.Fprintln(bw, "# frame key state")
+	for _, e := range r.events {
+		fmt.Fprintln(bw, e)
+	}
+	return bw.Flush()
+}
+
+// Save writes the recorded events to a file.
+func (r *Recorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := r.Write(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Player replays an input log.
+type Player struct {
+	seed   uint64
+	seeded bool
+	events []Event
+	next   int
+}
+
+// Read reads an input log.
+func Read(r io.Reader) (*Player, error) {
+	p := &Player{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		seed, ok, err := parseSeed(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ok {
+			if p.seeded || len(p.events) > 0 {
+				return nil, fmt.Errorf("line %d: the seed must be set once, before the events", line)
+			}
+			p.seed, p.seeded = seed, true
+			continue
+		}
+		e, err := parseEvent(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		p.events = append(p.events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(p.events, func(i, j int) bool {
+		return p.events[i].Frame < p.events[j].Frame
+	})
+	return p, nil
+}
+
+// Load reads an input log from a file.
+func Load(path string) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Seed returns the seed of the log, and false if it has none: logs recorded
+// before the seed was don't replay the random bytes of CXNN.
+func (p *Player) Seed() (uint64, bool) {
+	return p.seed, p.seeded
+}
+
+// Apply sets the keys that change at the start of the CPU's current frame,
+// and of any earlier frame that hasn't been applied yet.
+func (p *Player) Apply(chip8 *cpu.CPU) {
+	for p.next < len(p.events) && p.events[p.next].Frame <= chip8.Frame {
+		e := p.events[p.next]
+		chip8.SetKey(e.Key, e.Pressed)
+		p.next++
+	}
+}
+
+// Rewind starts the replay over.
+func (p *Player) Rewind() {
+	p.next = 0
+}
@@ -0,0 +1,161 @@
+package inputlog
+
+import (
+	"bytes"
+	"go-r8t/cpu"
+	"strings"
+	"testing"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    Event
+		wantErr bool
+	}{
+		{line: "120 5 down", want: Event{Frame: 120, Key: 5, Pressed: true}},
+		{line: "124 f up", want: Event{Frame: 124, Key: 0xF}},
+		{line: "0\tA\tdown", want: Event{Key: 0xA, Pressed: true}},
+		{line: "120 5", wantErr: true},
+		{line: "120 5 down now", wantErr: true},
+		{line: "-1 5 down", wantErr: true},
+		{line: "120 10 down", wantErr: true},
+		{line: "120 G down", wantErr: true},
+		{line: "120 5 pressed", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseEvent(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseEvent(%q) = %v, want an error", tt.line, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseEvent(%q) = %v, %v; want %v", tt.line, got, err, tt.want)
+		}
+		// Events round-trip through String
+		if again, err := parseEvent(got.String()); err != nil || again != got {
+			t.Errorf("parseEvent(%q) = %v, %v; want %v", got.String(), again, err, got)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	// Key changes recorded at the start of frames are replayed at the same frames
+	frames := []struct {
+		frame uint64
+		key   uint8
+		down  bool
+	}{
+		{3, 0x5, true},
+		{5, 0xA, true},
+		{8, 0x5, false},
+		{8, 0xA, false},
+	}
+	recorded := cpu.NewCPU()
+	recorder := NewRecorder(1234)
+	var want [10][16]bool
+	for frame := uint64(0); frame < 10; frame++ {
+		recorded.Frame = frame
+		for _, f := range frames {
+			if f.frame == frame {
+				recorded.SetKey(f.key, f.down)
+			}
+		}
+		recorder.Record(recorded)
+		want[frame] = recorded.Keys
+	}
+
+	var log bytes.Buffer
+	if err := recorder.Write(&log); err != nil {
+		t.Fatal(err)
+	}
+	player, err := Read(&log)
+	if err != nil {
+		t.Fatalf("Read:\n%s\n%v", log.String(), err)
+	}
+	if seed, ok := player.Seed(); !ok || seed != 1234 {
+		t.Errorf("Seed() = %d, %t; want 1234, true", seed, ok)
+	}
+	if len(player.events) != len(frames) {
+		t.Fatalf("read %d events, want %d:\n%s", len(player.events), len(frames), log.String())
+	}
+
+	for run := 0; run < 2; run++ {
+		player.Rewind()
+		replayed := cpu.NewCPU()
+		for frame := uint64(0); frame < 10; frame++ {
+			replayed.Frame = frame
+			player.Apply(replayed)
+			if replayed.Keys != want[frame] {
+				t.Fatalf("run %d, frame %d: keys %v, want %v", run, frame, replayed.Keys, want[frame])
+			}
+		}
+	}
+}
+
+func TestApplyCatchesUp(t *testing.T) {
+	// Events of frames that were skipped are applied in order
+	player, err := Read(strings.NewReader("2 1 down\n4 1 up\n4 2 down\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chip8 := cpu.NewCPU()
+	chip8.Frame = 6
+	player.Apply(chip8)
+	if chip8.Keys[1] || !chip8.Keys[2] {
+		t.Errorf("keys 1 and 2 = %t, %t; want false, true", chip8.Keys[1], chip8.Keys[2])
+	}
+}
+
+func TestRead(t *testing.T) {
+	log := `# frame key state
+seed 99
+
+10 3 down
+  # indented comment
+2 3 up
+`
+	player, err := Read(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed, ok := player.Seed(); !ok || seed != 99 {
+		t.Errorf("Seed() = %d, %t; want 99, true", seed, ok)
+	}
+	// Events are sorted by frame
+	want := []Event{{Frame: 2, Key: 3}, {Frame: 10, Key: 3, Pressed: true}}
+	if len(player.events) != len(want) || player.events[0] != want[0] || player.events[1] != want[1] {
+		t.Errorf("events = %v, want %v", player.events, want)
+	}
+
+	// Logs recorded before the seed have none
+	player, err = Read(strings.NewReader("1 0 down\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := player.Seed(); ok {
+		t.Error("a log without a seed has one")
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	tests := []struct {
+		name, log, want string
+	}{
+		{"event", "1 0 down\n2 0 sideways\n", "line 2: invalid key state"},
+		{"seed", "seed -1\n", "line 1: invalid seed"},
+		{"seed fields", "seed\n", "line 1: expected seed"},
+		{"seed twice", "seed 1\nseed 2\n", "line 2: the seed must be set once"},
+		{"seed after events", "1 0 down\nseed 1\n", "line 2: the seed must be set once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.log))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Read returned %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,89 +1,344 @@
 package main
 
 import (
+	"bytes"
 	"go-r8t/cpu"
-
+	"go-r8t/keymap"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/nsf/termbox-go"
 )
 
-// Map of terminal keys to CHIP-8 keypad
-var keyMap = map[termbox.Key]byte{
-	termbox.KeyF1:  0x1, // 1
-	termbox.KeyF2:  0x2, // 2
-	termbox.KeyF3:  0x3, // 3
-	termbox.KeyF4:  0xC, // 4 -> C
-	termbox.KeyF5:  0x4, // Q -> 4
-	termbox.KeyF6:  0x5, // W -> 5
-	termbox.KeyF7:  0x6, // E -> 6
-	termbox.KeyF8:  0xD, // R -> D
-	termbox.KeyF9:  0x7, // A -> 7
-	termbox.KeyF10: 0x8, // S -> 8
-	termbox.KeyF11: 0x9, // D -> 9
-	termbox.KeyF12: 0xE, // F -> E
+// Names of the non-printable termbox keys
+var termboxKeyNames = map[termbox.Key]string{
+	termbox.KeyF1:         "f1",
+	termbox.KeyF2:         "f2",
+	termbox.KeyF3:         "f3",
+	termbox.KeyF4:         "f4",
+	termbox.KeyF5:         "f5",
+	termbox.KeyF6:         "f6",
+	termbox.KeyF7:         "f7",
+	termbox.KeyF8:         "f8",
+	termbox.KeyF9:         "f9",
+	termbox.KeyF10:        "f10",
+	termbox.KeyF11:        "f11",
+	termbox.KeyF12:        "f12",
+	termbox.KeyArrowUp:    "up",
+	termbox.KeyArrowDown:  "down",
+	termbox.KeyArrowLeft:  "left",
+	termbox.KeyArrowRight: "right",
+	termbox.KeyInsert:     "insert",
+	termbox.KeyDelete:     "delete",
+	termbox.KeyHome:       "home",
+	termbox.KeyEnd:        "end",
+	termbox.KeyPgup:       "pageup",
+	termbox.KeyPgdn:       "pagedown",
+	termbox.KeyTab:        "tab",
+	termbox.KeyEnter:      "enter",
+	termbox.KeySpace:      "space",
+	termbox.KeyBackspace:  "backspace",
+	termbox.KeyBackspace2: "backspace",
+	termbox.KeyEsc:        "escape",
+}
+
+// termboxKeyName returns the keymap name of a termbox key or character
+func termboxKeyName(key termbox.Key, ch rune) string {
+	if ch != 0 {
+		return keymap.Normalize(string(ch))
+	}
+	return termboxKeyNames[key]
 }
 
-// Map of character runes to CHIP-8 keypad
-var runeKeyMap = map[rune]byte{
-	'1': 0x1, // 1
-	'2': 0x2, // 2
-	'3': 0x3, // 3
-	'4': 0xC, // 4 -> C
-	'q': 0x4, // q -> 4
-	'w': 0x5, // w -> 5
-	'e': 0x6, // e -> 6
-	'r': 0xD, // r -> D
-	'a': 0x7, // a -> 7
-	's': 0x8, // s -> 8
-	'd': 0x9, // d -> 9
-	'f': 0xE, // f -> E
-	'z': 0xA, // z -> A
-	'x': 0x0, // x -> 0
-	'c': 0xB, // c -> B
-	'v': 0xF, // v -> F
+// Terminals without release events only report presses and auto-repeats.
+// A key is considered released when no event arrived for it within these delays.
+// The initial delay has to cover the keyboard's auto-repeat delay.
+const (
+	initialReleaseDelay = 500 * time.Millisecond
+	repeatReleaseDelay  = 100 * time.Millisecond
+)
+
+// Kitty keyboard protocol escape sequences.
+// Flags 1|2|8 disambiguate keys, report press/repeat/release and report every key as an escape code.
+const (
+	kittyKeyboardPush  = "\x1b[>11u"
+	kittyKeyboardQuery = "\x1b[?u"
+	kittyKeyboardPop   = "\x1b[<u"
+)
+
+// KeyAction describes what happened to a key
+type KeyAction int
+
+const (
+	KeyPress KeyAction = iota
+	KeyRepeat
+	KeyRelease
+)
+
+// KeyEvent is a physical key event produced by the input goroutine
+type KeyEvent struct {
+	Name   string // Key name as used by the keymap
+	Action KeyAction
+}
+
+// heldKey is the state of a physical key that is held down
+type heldKey struct {
+	repeating bool      // Whether auto-repeat events were seen since the press
+	lastEvent time.Time // Time of the last press or repeat event
+}
+
+// Keyboard tracks which keys are held down in the terminal frontend.
+// The input goroutine only sends events; the state is owned by the emulation
+// loop, which applies it to the CPU between frames.
+type Keyboard struct {
+	Events chan KeyEvent
+
+	held          map[string]*heldKey
+	deferred      *KeyEvent       // Release held back until the next frame
+	binding       *bindSession    // Active key binding session, if any
+	console       *consoleSession // Open cheat console, if any
+	releaseEvents atomic.Bool     // Set once the terminal is known to report releases
 }
 
-// StartKeyboardInput initializes keyboard input handling in a separate goroutine
-func StartKeyboardInput(chip8 *cpu.CPU, quit chan struct{}) {
-	go func() {
-		for {
-			select {
-			case <-quit:
-				return
-			default:
-				ev := termbox.PollEvent()
-
-				switch ev.Type {
-				case termbox.EventKey:
-					if ev.Key == termbox.KeyEsc {
-						// Send signal to quit
-						close(quit)
-						return
-					}
-
-					// Handle key press
-					if chipKey, ok := keyMap[ev.Key]; ok {
-						chip8.SetKey(chipKey, true)
-
-						// Create a goroutine to handle auto-release after a short delay
-						go func(key byte) {
-							time.Sleep(100 * time.Millisecond)
-							chip8.SetKey(key, false)
-						}(chipKey)
-					} else if chipKey, ok := runeKeyMap[ev.Ch]; ok {
-						chip8.SetKey(chipKey, true)
-
-						// Create a goroutine to handle auto-release after a short delay
-						go func(key byte) {
-							time.Sleep(100 * time.Millisecond)
-							chip8.SetKey(key, false)
-						}(chipKey)
-					}
-				case termbox.EventError:
-					panic(ev.Err)
-				}
+// NewKeyboard creates a keyboard with an empty event queue
+func NewKeyboard() *Keyboard {
+	return &Keyboard{
+		Events: make(chan KeyEvent, 64),
+		held:   make(map[string]*heldKey),
+	}
+}
+
+// Update applies the queued key events to the CPU's keypad.
+// It must be called from the goroutine that executes the CPU.
+// It returns false when ESC was pressed to quit.
+// Parameters:
+//   - chip8: The CPU whose keys are updated
+//   - now: The current time, used to detect releases of keys that stopped repeating
+func (kb *Keyboard) Update(chip8 *cpu.CPU, now time.Time) bool {
+	pressed := make(map[string]bool)
+	for {
+		ev, ok := kb.next()
+		if !ok {
+			break
+		}
+		if ev.Action == KeyRelease && pressed[ev.Name] {
+			// A key tapped within one frame stays pressed for that frame,
+			// so the CPU sees it; the release and later events wait for the next frame.
+			kb.deferred = &ev
+			break
+		}
+		if ev.Action == KeyPress {
+			pressed[ev.Name] = true
+		}
+		if !kb.apply(chip8, ev, now) {
+			return false
+		}
+	}
+
+	if !kb.releaseEvents.Load() {
+		for name, key := range kb.held {
+			delay := initialReleaseDelay
+			if key.repeating {
+				delay = repeatReleaseDelay
 			}
+			if now.Sub(key.lastEvent) > delay {
+				delete(kb.held, name)
+			}
+		}
+	}
+
+	var keys [16]bool
+	for name := range kb.held {
+		if chipKey, ok := activeKeymap.Lookup(name); ok {
+			keys[chipKey] = true
+		}
+	}
+	for key := range keys {
+		chip8.SetKey(uint8(key), keys[key])
+	}
+	return true
+}
+
+// next returns the next queued event without blocking
+func (kb *Keyboard) next() (KeyEvent, bool) {
+	if ev := kb.deferred; ev != nil {
+		kb.deferred = nil
+		return *ev, true
+	}
+	select {
+	case ev := <-kb.Events:
+		return ev, true
+	default:
+		return KeyEvent{}, false
+	}
+}
+
+// Presses returns the names of the keys pressed since the last call,
+// for menus that don't need the held state.
+func (kb *Keyboard) Presses() []string {
+	kb.deferred = nil
+	var names []string
+	for {
+		select {
+		case ev := <-kb.Events:
+			if ev.Action == KeyPress {
+				names = append(names, ev.Name)
+			}
+			continue
+		default:
+		}
+		return names
+	}
+}
+
+// Paused reports whether the game is paused because the cheat console is open.
+func (kb *Keyboard) Paused() bool {
+	return kb.console != nil
+}
+
+// apply updates the held state of a key from a single event.
+// It returns false when ESC was pressed to quit.
+func (kb *Keyboard) apply(chip8 *cpu.CPU, ev KeyEvent, now time.Time) bool {
+	if ev.Action == KeyPress {
+		switch {
+		case kb.binding != nil:
+			if kb.binding.HandleKey(ev.Name) {
+				kb.binding = nil
+			}
+			return true
+		case kb.console != nil:
+			if kb.console.HandleKey(ev.Name) {
+				kb.console = nil
+			}
+			return true
+		case ev.Name == "escape":
+			return false
+		case ev.Name == bindKeyName:
+			kb.binding = startBinding()
+			return true
+		case ev.Name == consoleKeyName:
+			kb.console = startConsole(chip8)
+			return true
+		case ev.Name == saveStateKeyName:
+			saveState(chip8)
+			return true
+		case ev.Name == loadStateKeyName:
+			loadState(chip8)
+			return true
+		}
+	}
+
+	switch ev.Action {
+	case KeyPress, KeyRepeat:
+		key := kb.held[ev.Name]
+		if key == nil {
+			key = &heldKey{repeating: ev.Action == KeyRepeat}
+			kb.held[ev.Name] = key
+		} else {
+			// Without release events, a press of a held key is an auto-repeat
+			key.repeating = true
+		}
+		key.lastEvent = now
+	case KeyRelease:
+		delete(kb.held, ev.Name)
+	}
+	return true
+}
+
+// send queues an event for a named key
+func (kb *Keyboard) send(name string, action KeyAction) {
+	if name == "" {
+		return
+	}
+
+	select {
+	case kb.Events <- KeyEvent{Name: name, Action: action}:
+	default:
+		// Drop the event if the emulation loop is not keeping up
+	}
+}
+
+// Kitty keyboard protocol codes for keys that are not sent as CSI <codepoint> u
+var kittyFunctionKeys = map[string]termbox.Key{
+	"A": termbox.KeyArrowUp, "B": termbox.KeyArrowDown, "C": termbox.KeyArrowRight, "D": termbox.KeyArrowLeft,
+	"H": termbox.KeyHome, "F": termbox.KeyEnd,
+	"P": termbox.KeyF1, "Q": termbox.KeyF2, "R": termbox.KeyF3, "S": termbox.KeyF4,
+	"2~": termbox.KeyInsert, "3~": termbox.KeyDelete, "5~": termbox.KeyPgup, "6~": termbox.KeyPgdn,
+	"15~": termbox.KeyF5, "17~": termbox.KeyF6, "18~": termbox.KeyF7, "19~": termbox.KeyF8,
+	"20~": termbox.KeyF9, "21~": termbox.KeyF10, "23~": termbox.KeyF11, "24~": termbox.KeyF12,
+}
+
+// parseKittyKey parses a kitty keyboard protocol key event at the start of buf,
+// in the form CSI code[:alternates] ; modifiers[:event] final.
+// It returns the number of bytes consumed (0 if buf doesn't start with such a sequence)
+// and the key name, which is empty for keys that have no name.
+func parseKittyKey(buf []byte) (n int, name string, action KeyAction) {
+	if !bytes.HasPrefix(buf, []byte("\x1b[")) {
+		return 0, "", 0
+	}
+	end := 2
+	for end < len(buf) && (buf[end] >= '0' && buf[end] <= '9' || buf[end] == ';' || buf[end] == ':') {
+		end++
+	}
+	if end >= len(buf) {
+		return 0, "", 0
+	}
+	final := string(buf[end])
+	params := bytes.Split(buf[2:end], []byte(";"))
+
+	if _, ok := kittyFunctionKeys[final]; !ok && final != "u" && final != "~" {
+		return 0, "", 0
+	}
+
+	// The event type is omitted for presses
+	action = KeyPress
+	if len(params) >= 2 {
+		_, event, _ := bytes.Cut(params[1], []byte(":"))
+		switch string(event) {
+		case "2":
+			action = KeyRepeat
+		case "3":
+			action = KeyRelease
+		}
+	}
+
+	code, _, _ := bytes.Cut(params[0], []byte(":"))
+	n = end + 1
+	switch final {
+	case "u":
+		value, err := strconv.Atoi(string(code))
+		if err != nil {
+			return n, "", action
+		}
+		if value < 0x20 || value == int(termbox.KeyBackspace2) {
+			// Control keys (ESC, Enter, Tab, Backspace) are reported by their ASCII code
+			return n, termboxKeyNames[termbox.Key(value)], action
+		}
+		return n, termboxKeyName(0, rune(value)), action
+	case "~":
+		return n, termboxKeyNames[kittyFunctionKeys[string(code)+"~"]], action
+	default:
+		if key, ok := kittyFunctionKeys[final]; ok {
+			return n, termboxKeyNames[key], action
+		}
+		return n, "", action
+	}
+}
+
+// parseKittyReply parses the terminal's reply to the kitty keyboard query (CSI ? flags u)
+func parseKittyReply(buf []byte) (int, bool) {
+	if !bytes.HasPrefix(buf, []byte("\x1b[?")) {
+		return 0, false
+	}
+	for i := 3; i < len(buf); i++ {
+		switch {
+		case buf[i] >= '0' && buf[i] <= '9':
+		case buf[i] == 'u':
+			return i + 1, true
+		default:
+			return 0, false
 		}
-	}()
+	}
+	return 0, false
 }
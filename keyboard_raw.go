@@ -0,0 +1,61 @@
+//go:build !windows
+
+package main
+
+import "github.com/nsf/termbox-go"
+
+// StartKeyboardInput initializes keyboard input handling in a separate goroutine.
+// It enables the kitty keyboard protocol, which terminals that don't support it ignore.
+func StartKeyboardInput(kb *Keyboard, quit chan struct{}) {
+	writeTerminal(kittyKeyboardPush + kittyKeyboardQuery)
+
+	go func() {
+		data := make([]byte, 256)
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+				ev := termbox.PollRawEvent(data)
+
+				switch ev.Type {
+				case termbox.EventRaw:
+					kb.parseInput(data[:ev.N])
+				case termbox.EventError:
+					panic(ev.Err)
+				}
+			}
+		}
+	}()
+}
+
+// StopKeyboardInput restores the terminal's keyboard mode and wakes up the input goroutine
+func StopKeyboardInput() {
+	writeTerminal(kittyKeyboardPop)
+	termbox.Interrupt()
+}
+
+// parseInput converts raw terminal input into key events
+func (kb *Keyboard) parseInput(buf []byte) {
+	for len(buf) > 0 {
+		if n, name, action := parseKittyKey(buf); n > 0 {
+			buf = buf[n:]
+			kb.send(name, action)
+			continue
+		} else if n, ok := parseKittyReply(buf); ok {
+			// The terminal answered the query, so it reports key releases
+			kb.releaseEvents.Store(true)
+			buf = buf[n:]
+			continue
+		}
+
+		ev := termbox.ParseEvent(buf)
+		if ev.N == 0 {
+			break
+		}
+		buf = buf[ev.N:]
+		if ev.Type == termbox.EventKey {
+			kb.send(termboxKeyName(ev.Key, ev.Ch), KeyPress)
+		}
+	}
+}
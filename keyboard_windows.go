@@ -0,0 +1,31 @@
+package main
+
+import "github.com/nsf/termbox-go"
+
+// StartKeyboardInput initializes keyboard input handling in a separate goroutine.
+// The Windows console has no raw input or kitty keyboard protocol in termbox,
+// so only key presses are reported and releases are detected by timeout.
+func StartKeyboardInput(kb *Keyboard, quit chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+				ev := termbox.PollEvent()
+
+				switch ev.Type {
+				case termbox.EventKey:
+					kb.send(termboxKeyName(ev.Key, ev.Ch), KeyPress)
+				case termbox.EventError:
+					panic(ev.Err)
+				}
+			}
+		}
+	}()
+}
+
+// StopKeyboardInput wakes up the input goroutine
+func StopKeyboardInput() {
+	termbox.Interrupt()
+}
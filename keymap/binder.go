@@ -0,0 +1,67 @@
+package keymap
+
+// Binder implements the interactive "press a key to bind" flow.
+// It asks for a physical key for each CHIP-8 key in keypad order.
+type Binder struct {
+	index    int
+	bindings Bindings
+}
+
+// Keypad order used when binding, row by row as printed on the keypad
+var keypadOrder = [16]uint8{
+	0x1, 0x2, 0x3, 0xC,
+	0x4, 0x5, 0x6, 0xD,
+	0x7, 0x8, 0x9, 0xE,
+	0xA, 0x0, 0xB, 0xF,
+}
+
+// NewBinder starts a binding session.
+func NewBinder() *Binder {
+	return &Binder{}
+}
+
+// Current returns the CHIP-8 key waiting for a binding.
+// done is true once every key has been bound or skipped.
+func (b *Binder) Current() (key uint8, done bool) {
+	if b.index >= len(keypadOrder) {
+		return 0, true
+	}
+	return keypadOrder[b.index], false
+}
+
+// Bind assigns a physical key to the current CHIP-8 key and moves on to the next one.
+// Keys that were already used earlier in the session are ignored.
+// It returns true when the session is complete.
+func (b *Binder) Bind(name string) bool {
+	key, done := b.Current()
+	if done {
+		return true
+	}
+
+	name = Normalize(name)
+	for _, names := range b.bindings {
+		for _, n := range names {
+			if n == name {
+				return false
+			}
+		}
+	}
+
+	b.bindings[key] = []string{name}
+	b.index++
+	return b.index >= len(keypadOrder)
+}
+
+// Skip keeps the current CHIP-8 key's bindings and moves on to the next one.
+// It returns true when the session is complete.
+func (b *Binder) Skip() bool {
+	if b.index < len(keypadOrder) {
+		b.index++
+	}
+	return b.index >= len(keypadOrder)
+}
+
+// Bindings returns the bindings collected so far.
+func (b *Binder) Bindings() Bindings {
+	return b.bindings
+}
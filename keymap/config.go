@@ -0,0 +1,141 @@
+package keymap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-r8t/userdata"
+	"io/fs"
+	"os"
+)
+
+// Config is the keymap configuration file.
+//
+// Example:
+//
+//	{
+//	  "preset": "azerty",
+//	  "keys": {"5": ["z", "space"]},
+//	  "roms": {
+//	    "PONG.ch8": {
+//	      "keys": {"1": "up", "4": "down"},
+//	      "gamepad": {"up": "1", "down": "4"}
+//	    }
+//	  }
+//	}
+type Config struct {
+	Preset  string              `json:"preset,omitempty"`  // Base layout: qwerty, azerty or dvorak
+	Keys    Bindings            `json:"keys"`              // Overrides applied on top of the preset
+	Gamepad GamepadBindings     `json:"gamepad,omitempty"` // Replaces DefaultGamepad
+	ROMs    map[string]*Profile `json:"roms,omitempty"`    // Per-ROM overrides, keyed by ROM name
+}
+
+// Profile holds the keymap overrides for a single ROM.
+type Profile struct {
+	Preset  string          `json:"preset,omitempty"`  // Replaces the global preset
+	Keys    Bindings        `json:"keys"`              // Applied after the global overrides
+	Gamepad GamepadBindings `json:"gamepad,omitempty"` // Replaces the global gamepad bindings
+}
+
+// DefaultConfigPath returns the location of the keymap configuration file.
+func DefaultConfigPath() string {
+	return userdata.Path("keymap.json")
+}
+
+// LoadConfig reads a keymap configuration file.
+// A missing file is not an error and yields the default configuration.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Save writes the configuration file, creating its directory if needed.
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return userdata.WriteFile(path, append(data, '\n'))
+}
+
+// Bindings resolves the bindings for a ROM: the preset, then the keys the
+// ROM's defaults add to it, then the global overrides, then the ROM's own overrides.
+// Parameters:
+//   - rom: The ROM name, or "" for the global bindings
+//   - defaults: Default bindings for the ROM (e.g. from the ROM database), or nil
+func (c *Config) Bindings(rom string, defaults *Profile) (Bindings, error) {
+	preset := c.Preset
+	profile := c.ROMs[rom]
+	if profile != nil && profile.Preset != "" {
+		preset = profile.Preset
+	}
+	if preset == "" {
+		preset = DefaultPreset
+	}
+
+	base, ok := Presets[preset]
+	if !ok {
+		return Bindings{}, fmt.Errorf("unknown keymap preset %q", preset)
+	}
+	if defaults != nil {
+		base = base.Add(defaults.Keys)
+	}
+
+	bindings := base.Merge(c.Keys)
+	if profile != nil {
+		bindings = bindings.Merge(profile.Keys)
+	}
+	return bindings, nil
+}
+
+// Keymap resolves the lookup table for a ROM.
+func (c *Config) Keymap(rom string, defaults *Profile) (Keymap, error) {
+	bindings, err := c.Bindings(rom, defaults)
+	if err != nil {
+		return nil, err
+	}
+	return bindings.Keymap(), nil
+}
+
+// GamepadBindings resolves the gamepad bindings for a ROM.
+// Unlike keys, gamepad profiles are not merged: the most specific one wins,
+// from the ROM's overrides to the global bindings, the ROM's defaults and DefaultGamepad.
+func (c *Config) GamepadBindings(rom string, defaults *Profile) GamepadBindings {
+	if profile := c.ROMs[rom]; profile != nil && profile.Gamepad != nil {
+		return profile.Gamepad
+	}
+	if c.Gamepad != nil {
+		return c.Gamepad
+	}
+	if defaults != nil && defaults.Gamepad != nil {
+		return defaults.Gamepad
+	}
+	return DefaultGamepad
+}
+
+// SetROMBindings stores overrides for a ROM, replacing the ones bound to the same CHIP-8 keys.
+func (c *Config) SetROMBindings(rom string, overrides Bindings) {
+	if c.ROMs == nil {
+		c.ROMs = make(map[string]*Profile)
+	}
+	profile := c.ROMs[rom]
+	if profile == nil {
+		profile = &Profile{}
+		c.ROMs[rom] = profile
+	}
+	for key, names := range overrides {
+		if names != nil {
+			profile.Keys[key] = names
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package keymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Analog stick deflection needed to count as a direction button press
+const StickDeadzone = 0.5
+
+// GamepadBindings maps gamepad button names to CHIP-8 keys.
+//
+// Buttons use the names of the standard layout: "up", "down", "left", "right"
+// (D-pad, also triggered by the left stick), "a", "b", "x", "y", "l1", "r1",
+// "l2", "r2", "l3", "r3", "select", "start" and "home". Gamepads without a
+// standard layout mapping report their buttons as "button0", "button1", ...
+type GamepadBindings map[string]uint8
+
+// DefaultGamepad binds the D-pad to the 2/4/6/8 direction keys most games use,
+// and the face buttons to the keys around them.
+var DefaultGamepad = GamepadBindings{
+	"up":     0x2,
+	"down":   0x8,
+	"left":   0x4,
+	"right":  0x6,
+	"a":      0x5,
+	"b":      0x6,
+	"x":      0x4,
+	"y":      0x2,
+	"start":  0xF,
+	"select": 0xE,
+}
+
+// GamepadState holds which gamepad buttons are pressed, by button name.
+type GamepadState map[string]bool
+
+// SetStick presses the direction buttons an analog stick is pushed towards.
+// Parameters:
+//   - x: Horizontal axis, -1 (left) to 1 (right)
+//   - y: Vertical axis, -1 (up) to 1 (down)
+func (s GamepadState) SetStick(x, y float64) {
+	if x <= -StickDeadzone {
+		s["left"] = true
+	}
+	if x >= StickDeadzone {
+		s["right"] = true
+	}
+	if y <= -StickDeadzone {
+		s["up"] = true
+	}
+	if y >= StickDeadzone {
+		s["down"] = true
+	}
+}
+
+// Keys returns which CHIP-8 keys are held down for a gamepad state.
+func (g GamepadBindings) Keys(state GamepadState) [16]bool {
+	var keys [16]bool
+	for button, pressed := range state {
+		if key, ok := g[button]; pressed && ok {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// String lists the bindings in a stable order, e.g. "a:5 down:8 left:4".
+func (g GamepadBindings) String() string {
+	buttons := make([]string, 0, len(g))
+	for button := range g {
+		buttons = append(buttons, button)
+	}
+	sort.Strings(buttons)
+
+	parts := make([]string, len(buttons))
+	for i, button := range buttons {
+		parts[i] = fmt.Sprintf("%s:%X", button, g[button])
+	}
+	return strings.Join(parts, " ")
+}
+
+// MarshalJSON encodes the bindings with hexadecimal CHIP-8 keys, for example {"a": "5"}.
+func (g GamepadBindings) MarshalJSON() ([]byte, error) {
+	m := make(map[string]string, len(g))
+	for button, key := range g {
+		m[button] = fmt.Sprintf("%X", key)
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes bindings written by MarshalJSON.
+func (g *GamepadBindings) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	*g = make(GamepadBindings, len(m))
+	for button, hex := range m {
+		key, err := strconv.ParseUint(hex, 16, 8)
+		if err != nil || key > 0xF {
+			return fmt.Errorf("gamepad button %s: invalid CHIP-8 key %q", button, hex)
+		}
+		(*g)[strings.ToLower(button)] = uint8(key)
+	}
+	return nil
+}
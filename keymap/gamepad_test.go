@@ -0,0 +1,77 @@
+package keymap
+
+import "testing"
+
+func TestGamepadBindingsKeys(t *testing.T) {
+	bindings := GamepadBindings{"up": 0x2, "a": 0x5, "b": 0x5, "button3": 0xC}
+
+	tests := []struct {
+		name  string
+		state GamepadState
+		want  []uint8
+	}{
+		{"no buttons", GamepadState{}, nil},
+		{"one button", GamepadState{"up": true}, []uint8{0x2}},
+		{"released button", GamepadState{"up": false}, nil},
+		{"two buttons on one key", GamepadState{"a": true, "b": true}, []uint8{0x5}},
+		{"numbered button", GamepadState{"button3": true}, []uint8{0xC}},
+		{"unbound button", GamepadState{"start": true}, nil},
+		{"several keys", GamepadState{"up": true, "a": true, "y": true}, []uint8{0x2, 0x5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want [16]bool
+			for _, key := range tt.want {
+				want[key] = true
+			}
+			if got := bindings.Keys(tt.state); got != want {
+				t.Errorf("Keys(%v) = %v, want %v", tt.state, got, want)
+			}
+		})
+	}
+}
+
+func TestGamepadStateSetStick(t *testing.T) {
+	tests := []struct {
+		x, y float64
+		want []string
+	}{
+		{0, 0, nil},
+		{0.49, -0.49, nil},
+		{-StickDeadzone, 0, []string{"left"}},
+		{1, 0, []string{"right"}},
+		{0, -1, []string{"up"}},
+		{0, StickDeadzone, []string{"down"}},
+		{-0.8, 0.8, []string{"left", "down"}},
+		{0.7, -0.9, []string{"right", "up"}},
+	}
+	for _, tt := range tests {
+		state := GamepadState{}
+		state.SetStick(tt.x, tt.y)
+		if len(state) != len(tt.want) {
+			t.Errorf("SetStick(%v, %v) = %v, want %v", tt.x, tt.y, state, tt.want)
+			continue
+		}
+		for _, button := range tt.want {
+			if !state[button] {
+				t.Errorf("SetStick(%v, %v) = %v, want %v", tt.x, tt.y, state, tt.want)
+			}
+		}
+	}
+}
+
+func TestGamepadStickAndDPad(t *testing.T) {
+	// The stick presses the same buttons as the D-pad, so both trigger the bindings
+	state := GamepadState{"up": true}
+	state.SetStick(0, -1)
+	if keys := DefaultGamepad.Keys(state); !keys[0x2] {
+		t.Errorf("up on the D-pad and stick: key 2 not held")
+	}
+
+	state = GamepadState{}
+	state.SetStick(1, 1)
+	keys := DefaultGamepad.Keys(state)
+	if !keys[0x6] || !keys[0x8] {
+		t.Errorf("stick down-right: keys = %v, want 6 and 8 held", keys)
+	}
+}
@@ -0,0 +1,151 @@
+// Package keymap maps physical keyboard keys to the CHIP-8 hexadecimal keypad.
+//
+// Keys are identified by layout-independent names shared by all frontends:
+// printable keys use their lowercase character ("q", "1", ","), other keys use
+// lowercase names ("f1", "up", "space", "enter").
+package keymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Keymap maps physical key names to CHIP-8 keys (0x0-0xF).
+type Keymap map[string]uint8
+
+// Lookup returns the CHIP-8 key bound to the physical key name.
+func (k Keymap) Lookup(name string) (uint8, bool) {
+	key, ok := k[Normalize(name)]
+	return key, ok
+}
+
+// Bindings lists the physical keys bound to each CHIP-8 key.
+// A nil entry leaves the key unchanged when bindings are merged.
+type Bindings [16][]string
+
+// Keymap converts the bindings into a lookup table.
+func (b Bindings) Keymap() Keymap {
+	k := make(Keymap)
+	for key, names := range b {
+		for _, name := range names {
+			k[Normalize(name)] = uint8(key)
+		}
+	}
+	return k
+}
+
+// Merge returns a copy of b where every CHIP-8 key bound in overrides
+// has its physical keys replaced. Physical keys are removed from the
+// CHIP-8 keys they were previously bound to.
+func (b Bindings) Merge(overrides Bindings) Bindings {
+	var merged Bindings
+	for key := range b {
+		merged[key] = append([]string(nil), b[key]...)
+	}
+
+	for key, names := range overrides {
+		if names == nil {
+			continue
+		}
+		for _, name := range names {
+			for other := range merged {
+				merged[other] = remove(merged[other], Normalize(name))
+			}
+		}
+		merged[key] = nil
+		for _, name := range names {
+			merged[key] = append(merged[key], Normalize(name))
+		}
+	}
+	return merged
+}
+
+// Add returns a copy of b with the physical keys in extra bound in addition
+// to the existing ones, unless they are already bound to another CHIP-8 key.
+func (b Bindings) Add(extra Bindings) Bindings {
+	var merged Bindings
+	for key := range b {
+		merged[key] = append([]string(nil), b[key]...)
+	}
+
+	bound := b.Keymap()
+	for key, names := range extra {
+		for _, name := range names {
+			if _, ok := bound[Normalize(name)]; !ok {
+				merged[key] = append(merged[key], Normalize(name))
+			}
+		}
+	}
+	return merged
+}
+
+// MarshalJSON encodes the bindings as an object keyed by hexadecimal CHIP-8 key,
+// for example {"0": ["x"], "A": ["z"]}.
+func (b Bindings) MarshalJSON() ([]byte, error) {
+	m := make(map[string][]string)
+	for key, names := range b {
+		if names != nil {
+			m[fmt.Sprintf("%X", key)] = names
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes bindings written by MarshalJSON.
+// A single physical key may be given as a string instead of a list.
+func (b *Bindings) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	*b = Bindings{}
+	for hex, raw := range m {
+		key, err := strconv.ParseUint(hex, 16, 8)
+		if err != nil || key > 0xF {
+			return fmt.Errorf("invalid CHIP-8 key %q", hex)
+		}
+
+		var names []string
+		if err := json.Unmarshal(raw, &names); err != nil {
+			var name string
+			if err := json.Unmarshal(raw, &name); err != nil {
+				return fmt.Errorf("key %s: expected a key name or a list of key names", hex)
+			}
+			names = []string{name}
+		}
+		if names == nil {
+			names = []string{}
+		}
+		b[key] = names
+	}
+	return nil
+}
+
+// Normalize converts a key name into its canonical form.
+func Normalize(name string) string {
+	if utf8.RuneCountInString(name) == 1 {
+		if name == " " {
+			return "space"
+		}
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// remove returns names without name.
+func remove(names []string, name string) []string {
+	if names == nil {
+		return nil
+	}
+	out := []string{}
+	for _, n := range names {
+		if n != name {
+			out = append(out, n)
+		}
+	}
+	return out
+}
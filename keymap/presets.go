@@ -0,0 +1,50 @@
+package keymap
+
+// Function keys are bound the same way in every preset:
+// F1-F4 to the first row of the keypad, F5-F8 to the second and F9-F12 to the third.
+var functionKeys = Bindings{
+	0x1: {"f1"}, 0x2: {"f2"}, 0x3: {"f3"}, 0xC: {"f4"},
+	0x4: {"f5"}, 0x5: {"f6"}, 0x6: {"f7"}, 0xD: {"f8"},
+	0x7: {"f9"}, 0x8: {"f10"}, 0x9: {"f11"}, 0xE: {"f12"},
+}
+
+// Presets bind the 4x4 block of keys below the number row to the keypad,
+// so the keypad has the same physical shape on every layout:
+//
+//	CHIP-8 Keypad    QWERTY     AZERTY     Dvorak
+//	1 2 3 C          1 2 3 4    & é " '    1 2 3 4
+//	4 5 6 D          Q W E R    A Z E R    ' , . P
+//	7 8 9 E          A S D F    Q S D F    A O E U
+//	A 0 B F          Z X C V    W X C V    ; Q J K
+var Presets = map[string]Bindings{
+	"qwerty": withFunctionKeys(Bindings{
+		0x1: {"1"}, 0x2: {"2"}, 0x3: {"3"}, 0xC: {"4"},
+		0x4: {"q"}, 0x5: {"w"}, 0x6: {"e"}, 0xD: {"r"},
+		0x7: {"a"}, 0x8: {"s"}, 0x9: {"d"}, 0xE: {"f"},
+		0xA: {"z"}, 0x0: {"x"}, 0xB: {"c"}, 0xF: {"v"},
+	}),
+	"azerty": withFunctionKeys(Bindings{
+		// The digits are also bound, since they are typed with Shift on AZERTY
+		0x1: {"&", "1"}, 0x2: {"é", "2"}, 0x3: {"\"", "3"}, 0xC: {"'", "4"},
+		0x4: {"a"}, 0x5: {"z"}, 0x6: {"e"}, 0xD: {"r"},
+		0x7: {"q"}, 0x8: {"s"}, 0x9: {"d"}, 0xE: {"f"},
+		0xA: {"w"}, 0x0: {"x"}, 0xB: {"c"}, 0xF: {"v"},
+	}),
+	"dvorak": withFunctionKeys(Bindings{
+		0x1: {"1"}, 0x2: {"2"}, 0x3: {"3"}, 0xC: {"4"},
+		0x4: {"'"}, 0x5: {","}, 0x6: {"."}, 0xD: {"p"},
+		0x7: {"a"}, 0x8: {"o"}, 0x9: {"e"}, 0xE: {"u"},
+		0xA: {";"}, 0x0: {"q"}, 0xB: {"j"}, 0xF: {"k"},
+	}),
+}
+
+// DefaultPreset is used when the configuration doesn't name a preset.
+const DefaultPreset = "qwerty"
+
+// withFunctionKeys adds the function key bindings to a preset.
+func withFunctionKeys(b Bindings) Bindings {
+	for key, names := range functionKeys {
+		b[key] = append(b[key], names...)
+	}
+	return b
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"go-r8t/library"
+	"sync"
+)
+
+// Launcher lists the ROMs of a library directory and lets the user pick one to boot.
+// It is shared by both frontends, which only render its lines and forward key presses.
+// The directory is scanned in the background, since scanning a large tree
+// (e.g. a home directory) can take a long time; the list is replaced when
+// the scan finishes.
+type Launcher struct {
+	Dir      string
+	mu       sync.Mutex // Guards the fields below, which the scan replaces
+	entries  []library.Entry
+	selected int
+	err      error
+	scans    int // Number of scans started; only the latest one's result is kept
+	scanning bool
+}
+
+// NewLauncher creates a launcher for a ROM directory and starts scanning it
+func NewLauncher(dir string) *Launcher {
+	l := &Launcher{Dir: dir}
+	l.Refresh()
+	return l
+}
+
+// Refresh starts rescanning the library directory.
+// When the scan finishes, the selected ROM stays selected if it is still there.
+func (l *Launcher) Refresh() {
+	l.mu.Lock()
+	l.scans++
+	scan := l.scans
+	l.scanning = true
+	l.mu.Unlock()
+
+	go func() {
+		entries, err := library.Scan(l.Dir, romDatabase, playHistory)
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if scan != l.scans {
+			return // A newer scan replaces this one
+		}
+		var selectedPath string
+		if l.selected < len(l.entries) {
+			selectedPath = l.entries[l.selected].Path
+		}
+		l.entries, l.err, l.scanning = entries, err, false
+		l.selected = 0
+		for i, entry := range l.entries {
+			if entry.Path == selectedPath {
+				l.selected = i
+			}
+		}
+	}()
+}
+
+// HandleKey processes a key press in the launcher.
+// It returns the path of the ROM to boot, if any, and whether to quit.
+func (l *Launcher) HandleKey(name string) (boot string, quit bool) {
+	if name == "r" {
+		l.Refresh()
+		return "", false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch name {
+	case "escape":
+		return "", true
+	case "up", "k":
+		l.selected--
+	case "down", "j":
+		l.selected++
+	case "pageup":
+		l.selected -= 10
+	case "pagedown":
+		l.selected += 10
+	case "home":
+		l.selected = 0
+	case "end":
+		l.selected = len(l.entries) - 1
+	case "enter", "space":
+		if l.selected < len(l.entries) {
+			return l.entries[l.selected].Path, false
+		}
+	}
+	l.selected = max(0, min(l.selected, len(l.entries)-1))
+	return "", false
+}
+
+// Lines returns the launcher text, scrolled so that the selected ROM is visible.
+// Parameters:
+//   - rows: The number of lines available
+//   - cols: The maximum line length
+func (l *Launcher) Lines(rows, cols int) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lines := []string{
+		"ROM library: " + l.Dir,
+		"Enter: play  Up/Down: select  R: rescan  ESC: quit",
+		"",
+	}
+	switch {
+	case l.err != nil:
+		lines = append(lines, "Error: "+l.err.Error())
+	case l.scanning && len(l.entries) == 0:
+		lines = append(lines, "Scanning...")
+	case len(l.entries) == 0:
+		lines = append(lines, "No .ch8, .sc8 or .xo8 files found")
+	}
+
+	// Scroll the list so that the selection stays on screen
+	listRows := max(1, rows-len(lines))
+	first := max(0, l.selected-listRows+1)
+	for i := first; i < len(l.entries) && i < first+listRows; i++ {
+		entry := l.entries[i]
+		cursor := "  "
+		if i == l.selected {
+			cursor = "> "
+		}
+		played := "never played"
+		if !entry.LastPlayed.IsZero() {
+			played = entry.LastPlayed.Format("2006-01-02 15:04")
+		}
+		lines = append(lines, fmt.Sprintf("%s%-12s %-16s %s", cursor, entry.Platform, played, entry.Title))
+	}
+
+	for i, line := range lines {
+		if runes := []rune(line); len(runes) > cols {
+			lines[i] = string(runes[:cols])
+		}
+	}
+	return lines
+}
@@ -0,0 +1,141 @@
+// Package library scans directories for CHIP-8 ROMs and remembers when they were last played.
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"go-r8t/cpu"
+	"go-r8t/romdb"
+	"go-r8t/userdata"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a ROM found in the library.
+type Entry struct {
+	Path       string       // Path of the ROM file
+	Title      string       // Title from the ROM database, or the file name
+	Platform   cpu.Platform // Platform from the ROM database, or guessed by romdb.Identify
+	LastPlayed time.Time    // Zero if the ROM was never played
+}
+
+// Scan lists the ROMs in dir and its subdirectories.
+// Subdirectories that can't be read are skipped.
+// Recently played ROMs come first, followed by the others in title order.
+// Parameters:
+//   - dir: The directory to scan
+//   - db: The ROM database used for titles and platforms, or nil
+//   - history: The play history used for the last played times, or nil
+func Scan(dir string, db *romdb.Database, history *History) ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// Skip unreadable subdirectories and files instead of failing the whole scan
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !romdb.IsROMFile(path) {
+			return nil
+		}
+
+		entry := Entry{
+			Path:  path,
+			Title: strings.TrimSuffix(d.Name(), filepath.Ext(path)),
+		}
+		if rom, err := os.ReadFile(path); err == nil {
+			if settings, ok := romdb.Identify(db, path, rom); ok {
+				entry.Platform = settings.Platform
+				if settings.Name != "" {
+					entry.Title = settings.Name
+				}
+			}
+		}
+		if history != nil {
+			entry.LastPlayed = history.LastPlayed(path)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.LastPlayed.Equal(b.LastPlayed) {
+			return a.LastPlayed.After(b.LastPlayed)
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+	return entries, nil
+}
+
+// History records when ROMs were last played.
+// It is safe to use from several goroutines, e.g. while a Scan runs in the background.
+type History struct {
+	path   string
+	mu     sync.Mutex
+	Played map[string]time.Time `json:"played"` // Keyed by absolute ROM path
+}
+
+// DefaultHistoryPath returns the location of the play history file.
+func DefaultHistoryPath() string {
+	return userdata.Path("history.json")
+}
+
+// LoadHistory reads the play history. A missing file yields an empty history.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path, Played: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, err
+	}
+	if h.Played == nil {
+		h.Played = make(map[string]time.Time)
+	}
+	return h, nil
+}
+
+// LastPlayed returns when a ROM was last played, or the zero time.
+func (h *History) LastPlayed(romPath string) time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.Played[absPath(romPath)]
+}
+
+// MarkPlayed records that a ROM was played now and saves the history.
+func (h *History) MarkPlayed(romPath string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Played[absPath(romPath)] = time.Now()
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return userdata.WriteFile(h.path, append(data, '\n'))
+}
+
+// absPath makes ROM paths independent of the working directory.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -1,120 +1,355 @@
 package main
 
 import (
+	"flag"
+	"go-r8t/cheats"
 	"go-r8t/cpu"
-	"image/color"
+	"go-r8t/keymap"
+	"go-r8t/library"
+	"go-r8t/romdb"
+	"go-r8t/userdata"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
+// Number of screen pixels per CHIP-8 pixel in the ebiten frontend
+const pixelScale = 4
+
+// Size of the ebiten window, also used as the launcher's screen size
+const windowWidth, windowHeight = 640, 320
+
+// Size of the characters drawn by ebitenutil.DebugPrint
+const debugCharWidth, debugCharHeight = 6, 16
+
 // Game represents the main game state
 type Game struct {
-	cpu *cpu.CPU
+	cpu         *cpu.CPU
+	framebuffer *Framebuffer
+	binding     *bindSession    // Active key binding session, if any
+	console     *consoleSession // Open cheat console, if any
+	gamepads    Gamepads
+	launcher    *Launcher // ROM library, nil if the emulator was started without one
+	inLauncher  bool      // Whether the launcher is shown instead of the game
 }
 
 // NewGame creates a new game instance
 func NewGame() *Game {
 	g := &Game{
-		cpu: cpu.NewCPU(),
+		cpu:         cpu.NewCPU(),
+		framebuffer: NewFramebuffer(64, 32, pixelScale),
 	}
 	return g
 }
 
+// Boot starts the ROM at path with a fresh CPU
+func (g *Game) Boot(path string) error {
+	chip8, err := bootROM(path)
+	if err != nil {
+		return err
+	}
+	g.cpu = chip8
+	g.inLauncher = false
+
+	// Start with a clean framebuffer in the ROM's colors
+	width, height := chip8.DisplaySize()
+	g.framebuffer = NewFramebuffer(width, height, pixelScale)
+	if len(romPalette) >= 2 {
+		g.framebuffer.Background = romPalette[0]
+		g.framebuffer.Foreground = romPalette[1]
+	}
+	ebiten.SetWindowTitle(windowTitle())
+	return nil
+}
+
 // Update updates the game state
 func (g *Game) Update() error {
+	// Track hotplugged gamepads in every screen, so none are missed
+	g.gamepads.Update()
+
+	if g.inLauncher {
+		return g.updateLauncher()
+	}
+
+	// The game is paused while the cheat console is open
+	if g.console != nil {
+		for _, key := range inpututil.AppendJustPressedKeys(nil) {
+			if g.console.HandleKey(ebitenKeyName(key)) {
+				g.console = nil
+				break
+			}
+		}
+		return nil
+	}
+
+	// ESC returns to the launcher, unless it cancels a key binding session
+	if g.launcher != nil && g.binding == nil && inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		err := endSession(g.cpu)
+		g.inLauncher = true
+		g.launcher.Refresh()
+		SetStatus("")
+		if err != nil {
+			SetStatus(err.Error())
+		}
+		return nil
+	}
+
 	// Handle input
 	g.handleInput()
+	g.handleCRTToggles()
 
-	// Run one CPU cycle
-	opcode := (uint16(g.cpu.Memory[g.cpu.PC]) << 8) | uint16(g.cpu.Memory[g.cpu.PC+1])
-	g.cpu.ExecuteInstruction(opcode)
+	runFrame(g.cpu)
 
-	// Update timers at 60Hz
-	g.cpu.UpdateTimers()
+	// Render the CHIP-8 display into the framebuffer once per frame, since
+	// Draw runs once per display refresh and persistence decays per update
+	display := g.cpu.Snapshot()
+	g.framebuffer.Update(display.Pixels, display.Width, display.Height, g.cpu.TakeDirty())
 
 	return nil
 }
 
-// Draw draws the game screen
-func (g *Game) Draw(screen *ebiten.Image) {
-	// Clear screen
-	screen.Fill(color.RGBA{0, 0, 0, 255})
-
-	// Get screen dimensions
-	screenWidth, screenHeight := screen.Bounds().Dx(), screen.Bounds().Dy()
-
-	// Calculate pixel size to maintain aspect ratio
-	pixelWidth := float64(screenWidth) / 64
-	pixelHeight := float64(screenHeight) / 32
-
-	// Draw CHIP-8 display
-	pixelsDrawn := 0
-	for y := 0; y < 32; y++ {
-		for x := 0; x < 64; x++ {
-			if g.cpu.Display[y*64+x] == 1 {
-				ebitenutil.DrawRect(
-					screen,
-					float64(x)*pixelWidth,
-					float64(y)*pixelHeight,
-					pixelWidth,
-					pixelHeight,
-					color.RGBA{255, 255, 255, 255},
-				)
-				pixelsDrawn++
+// updateLauncher handles key presses while the launcher is shown
+func (g *Game) updateLauncher() error {
+	for _, key := range inpututil.AppendJustPressedKeys(nil) {
+		boot, quit := g.launcher.HandleKey(ebitenKeyName(key))
+		if quit {
+			return ebiten.Termination
+		}
+		if boot != "" {
+			if err := g.Boot(boot); err != nil {
+				SetStatus(err.Error())
 			}
+			return nil
 		}
 	}
+	return nil
+}
+
+// Draw draws the game screen
+func (g *Game) Draw(screen *ebiten.Image) {
+	if g.inLauncher {
+		rows := screen.Bounds().Dy()/debugCharHeight - 1
+		cols := screen.Bounds().Dx() / debugCharWidth
+		ebitenutil.DebugPrint(screen, strings.Join(g.launcher.Lines(rows, cols), "\n"))
+		ebitenutil.DebugPrintAt(screen, statusMessage, 0, rows*debugCharHeight)
+		return
+	}
+
+	// Stretch the framebuffer over the whole screen
+	img := g.framebuffer.Image()
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(
+		float64(screen.Bounds().Dx())/float64(img.Bounds().Dx()),
+		float64(screen.Bounds().Dy())/float64(img.Bounds().Dy()),
+	)
+	screen.DrawImage(img, op)
+
+	if statusMessage != "" {
+		ebitenutil.DebugPrint(screen, statusMessage)
+	}
+	g.gamepads.Draw(screen)
+}
 
-	// Log display state for debugging
-	if pixelsDrawn > 0 {
-		log.Printf("Display: %d pixels drawn, screen size: %dx%d, pixel size: %.2fx%.2f",
-			pixelsDrawn, screenWidth, screenHeight, pixelWidth, pixelHeight)
+// windowTitle returns the window title, including the ROM title when a ROM is loaded
+func windowTitle() string {
+	if currentROM == noROM {
+		return "CHIP-8 Emulator"
 	}
+	return currentROM + " - CHIP-8 Emulator"
 }
 
 // Layout implements ebiten.Game's Layout
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	return 256, 128 // Very small CHIP-8 display
+	if g.inLauncher {
+		// Text is drawn at a fixed size, so the launcher uses the window's full resolution
+		return windowWidth, windowHeight
+	}
+	// The screen has the aspect ratio of the platform's display
+	return g.framebuffer.Image().Bounds().Dx(), g.framebuffer.Image().Bounds().Dy()
+}
+
+// handleCRTToggles switches the framebuffer post-processing effects on and off
+func (g *Game) handleCRTToggles() {
+	settings := &g.framebuffer.Settings
+	if inpututil.IsKeyJustPressed(ebiten.KeyF1) {
+		settings.Persistence = !settings.Persistence
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF2) {
+		settings.Scanlines = !settings.Scanlines
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+		settings.PixelGrid = !settings.PixelGrid
+	}
 }
 
-// handleInput updates the CPU's key state based on keyboard input
+// handleInput updates the CPU's key state based on keyboard and gamepad input
 func (g *Game) handleInput() {
-	// CHIP-8 keypad mapping to keyboard
-	keyMap := map[ebiten.Key]uint8{
-		ebiten.Key1: 0x1,
-		ebiten.Key2: 0x2,
-		ebiten.Key3: 0x3,
-		ebiten.Key4: 0xC,
-		ebiten.KeyQ: 0x4,
-		ebiten.KeyW: 0x5,
-		ebiten.KeyE: 0x6,
-		ebiten.KeyR: 0xD,
-		ebiten.KeyA: 0x7,
-		ebiten.KeyS: 0x8,
-		ebiten.KeyD: 0x9,
-		ebiten.KeyF: 0xE,
-		ebiten.KeyZ: 0xA,
-		ebiten.KeyX: 0x0,
-		ebiten.KeyC: 0xB,
-		ebiten.KeyV: 0xF,
+	// Keys pressed during a binding session are not passed to the game
+	if g.binding != nil {
+		for _, key := range inpututil.AppendJustPressedKeys(nil) {
+			if g.binding.HandleKey(ebitenKeyName(key)) {
+				g.binding = nil
+				break
+			}
+		}
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		g.binding = startBinding()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackquote) {
+		g.console = startConsole(g.cpu)
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) {
+		saveState(g.cpu)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) {
+		loadState(g.cpu)
 	}
 
 	// Update key states
-	for key, value := range keyMap {
-		g.cpu.SetKey(value, ebiten.IsKeyPressed(key))
+	keys := g.gamepads.Keys()
+	for _, key := range inpututil.AppendPressedKeys(nil) {
+		if isReservedKey(key) {
+			continue
+		}
+		if value, ok := activeKeymap.Lookup(ebitenKeyName(key)); ok {
+			keys[value] = true
+		}
+	}
+	for value, pressed := range keys {
+		g.cpu.SetKey(uint8(value), pressed)
+	}
+}
+
+// isReservedKey reports whether a key is used by the GUI itself
+func isReservedKey(key ebiten.Key) bool {
+	switch key {
+	case ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4, ebiten.KeyTab, ebiten.KeyBackquote,
+		ebiten.KeyMinus, ebiten.KeyEqual:
+		return true
+	}
+	return false
+}
+
+// Names of ebiten keys whose String() differs from the keymap name
+var ebitenKeyNames = map[ebiten.Key]string{
+	ebiten.KeyArrowUp:      "up",
+	ebiten.KeyArrowDown:    "down",
+	ebiten.KeyArrowLeft:    "left",
+	ebiten.KeyArrowRight:   "right",
+	ebiten.KeyComma:        ",",
+	ebiten.KeyPeriod:       ".",
+	ebiten.KeySemicolon:    ";",
+	ebiten.KeyQuote:        "'",
+	ebiten.KeySlash:        "/",
+	ebiten.KeyBackslash:    "\\",
+	ebiten.KeyMinus:        "-",
+	ebiten.KeyEqual:        "=",
+	ebiten.KeyBackquote:    "`",
+	ebiten.KeyBracketLeft:  "[",
+	ebiten.KeyBracketRight: "]",
+}
+
+// ebitenKeyName returns the keymap name of a key.
+// Printable keys are named after the character they produce in the current
+// keyboard layout, so the layout presets work the same as in the terminal.
+func ebitenKeyName(key ebiten.Key) string {
+	if name := ebiten.KeyName(key); name != "" {
+		return keymap.Normalize(name)
+	}
+	if name, ok := ebitenKeyNames[key]; ok {
+		return name
 	}
+	return keymap.Normalize(strings.TrimPrefix(key.String(), "Digit"))
 }
 
 var game *Game
 
 func main() {
+	if ok, err := runCommand(os.Args[1:]); ok {
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	terminal := flag.Bool("terminal", false, "run in the terminal instead of a window")
+	render := flag.String("render", "auto", "terminal render mode: auto, block, half, braille, sixel or kitty")
+	keymapPath := flag.String("keymap", keymap.DefaultConfigPath(), "keymap configuration file")
+	cheatsPath := flag.String("cheats", cheats.DefaultConfigPath(), "cheat file")
+	romdbPath := flag.String("romdb", romdb.DefaultOverridePath(), "local ROM database with additional or replacement entries")
+	patchPath := flag.String("patch", "", "IPS or BPS patch to apply to the ROM (default: a .bps or .ips file next to the ROM)")
+	flag.StringVar(&coveragePrefix, "coverage", "", "write the coverage of each session to `prefix`.lst and prefix.png")
+	flag.StringVar(&profilePath, "profile", "", "write a pprof profile of each session to `file`")
+	flag.StringVar(&recordPath, "record", "", "write the keypad input of each session to `file`, for the -input flag of coverage and profile")
+	flag.Func("platform", platformUsage, setPlatform)
+	flag.Func("memory", memoryPolicyUsage, setMemoryPolicy)
+	flag.Func("font", fontUsage, setFont)
+	flag.Func("font-address", "interpreter area `address` to load the font at (default: the platform's)", setFontAddress)
+	libraryDir := flag.String("library", "", "directory of ROMs to show in the launcher (default: current directory if no ROM is given)")
+	flag.Parse()
+
+	if err := LoadROMDatabase(*romdbPath); err != nil {
+		log.Fatal(err)
+	}
+	if err := LoadKeymap(*keymapPath); err != nil {
+		log.Fatal(err)
+	}
+	if err := LoadCheats(*cheatsPath); err != nil {
+		log.Fatal(err)
+	}
+	flagsDir = userdata.Path("flags")
+	history, err := library.LoadHistory(library.DefaultHistoryPath())
+	if err != nil {
+		log.Fatal(err)
+	}
+	playHistory = history
+
+	// Without a ROM to boot, the launcher lists the current directory
 	game = NewGame()
+	if *libraryDir == "" && flag.NArg() == 0 {
+		*libraryDir = "."
+	}
+	if *libraryDir != "" {
+		game.launcher = NewLauncher(*libraryDir)
+	}
+	if flag.NArg() > 0 {
+		if *patchPath != "" {
+			romPatches[flag.Arg(0)] = *patchPath
+		}
+		if err := game.Boot(flag.Arg(0)); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		game.inLauncher = true
+	}
+
+	if *terminal {
+		mode, err := ParseRenderMode(*render)
+		if err != nil {
+			log.Fatal(err)
+		}
+		SetRenderMode(mode)
+		var chip8 *cpu.CPU
+		if !game.inLauncher {
+			chip8 = game.cpu
+		}
+		if err := RunTerminal(chip8, game.launcher); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// Configure the window
-	ebiten.SetWindowTitle("CHIP-8 Emulator")
-	ebiten.SetWindowSize(640, 320)
+	ebiten.SetWindowTitle(windowTitle())
+	ebiten.SetWindowSize(windowWidth, windowHeight)
 	ebiten.SetWindowResizable(true)
 	ebiten.SetMaxTPS(60) // Limit to 60 frames per second
 
@@ -122,4 +357,9 @@ func main() {
 	if err := ebiten.RunGame(game); err != nil {
 		log.Fatal(err)
 	}
+	if !game.inLauncher {
+		if err := endSession(game.cpu); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
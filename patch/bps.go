@@ -0,0 +1,203 @@
+package patch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// BPS patches are a "BPS1" header, the source, target and metadata sizes,
+// the metadata, a list of actions and a footer with the CRC32 of the source,
+// the target and the patch itself.
+const (
+	bpsMagic      = "BPS1"
+	bpsFooterSize = 12
+)
+
+// BPS actions, stored in the low 2 bits of each action
+const (
+	bpsSourceRead = iota // Copy from the source at the output offset
+	bpsTargetRead        // Copy bytes stored in the patch
+	bpsSourceCopy        // Copy from a relative offset in the source
+	bpsTargetCopy        // Copy from a relative offset in the output written so far
+)
+
+// ApplyBPS patches a ROM with a BPS patch.
+// The checksums of the patch, the source ROM and the result are all verified,
+// so a patch made for a different ROM is rejected.
+func ApplyBPS(source, patch []byte) ([]byte, error) {
+	if len(patch) < len(bpsMagic)+bpsFooterSize || string(patch[:len(bpsMagic)]) != bpsMagic {
+		return nil, errors.New("not a BPS patch")
+	}
+	footer := patch[len(patch)-bpsFooterSize:]
+	sourceCRC := binary.LittleEndian.Uint32(footer[0:])
+	targetCRC := binary.LittleEndian.Uint32(footer[4:])
+	patchCRC := binary.LittleEndian.Uint32(footer[8:])
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != patchCRC {
+		return nil, errors.New("patch checksum mismatch, the patch file is corrupt")
+	}
+	if crc32.ChecksumIEEE(source) != sourceCRC {
+		return nil, errors.New("ROM checksum mismatch, the patch is for a different ROM")
+	}
+
+	r := &bpsReader{data: patch[:len(patch)-bpsFooterSize], pos: len(bpsMagic)}
+	sourceSize := r.number()
+	targetSize := r.number()
+	metadataSize := r.number()
+	if r.err != nil || sourceSize != uint64(len(source)) || targetSize > 1<<24 {
+		return nil, errors.New("invalid BPS header")
+	}
+	r.skip(int(metadataSize))
+
+	target := make([]byte, targetSize)
+	var outputOffset, sourceRelative, targetRelative int
+	for r.err == nil && r.pos < len(r.data) {
+		action := r.number()
+		length := int(action>>2) + 1
+		if outputOffset+length > len(target) {
+			return nil, errors.New("patch writes past the end of the ROM")
+		}
+
+		switch action & 3 {
+		case bpsSourceRead:
+			if outputOffset+length > len(source) {
+				return nil, errors.New("patch reads past the end of the ROM")
+			}
+			copy(target[outputOffset:], source[outputOffset:outputOffset+length])
+		case bpsTargetRead:
+			copy(target[outputOffset:], r.bytes(length))
+		case bpsSourceCopy:
+			sourceRelative += r.offset()
+			if sourceRelative < 0 || sourceRelative+length > len(source) {
+				return nil, errors.New("patch reads past the end of the ROM")
+			}
+			copy(target[outputOffset:], source[sourceRelative:sourceRelative+length])
+			sourceRelative += length
+		case bpsTargetCopy:
+			targetRelative += r.offset()
+			if targetRelative < 0 || targetRelative >= outputOffset {
+				return nil, errors.New("patch copies from outside the output")
+			}
+			// Byte by byte, since the ranges may overlap to repeat a pattern
+			for i := 0; i < length; i++ {
+				target[outputOffset+i] = target[targetRelative]
+				targetRelative++
+			}
+		}
+		outputOffset += length
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if crc32.ChecksumIEEE(target) != targetCRC {
+		return nil, errors.New("patched ROM checksum mismatch")
+	}
+	return target, nil
+}
+
+// CreateBPS makes a BPS patch that turns source into target.
+// Bytes that are unchanged are read from the source; all others are stored in the patch.
+func CreateBPS(source, target []byte) []byte {
+	w := &bpsWriter{}
+	w.buf = append(w.buf, bpsMagic...)
+	w.number(uint64(len(source)))
+	w.number(uint64(len(target)))
+	w.number(0) // No metadata
+
+	for i := 0; i < len(target); {
+		same := func(j int) bool { return j < len(source) && source[j] == target[j] }
+		end := i + 1
+		for end < len(target) && same(end) == same(i) {
+			end++
+		}
+		if same(i) {
+			w.number(uint64(end-i-1)<<2 | bpsSourceRead)
+		} else {
+			w.number(uint64(end-i-1)<<2 | bpsTargetRead)
+			w.buf = append(w.buf, target[i:end]...)
+		}
+		i = end
+	}
+
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(source))
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(target))
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(w.buf))
+	return w.buf
+}
+
+// bpsReader decodes the variable-length numbers of a BPS patch.
+// After the first error, all reads return zero values.
+type bpsReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// number reads a variable-length number: 7 bits per byte, with the
+// high bit set on the last byte and an offset added for every extra byte.
+func (r *bpsReader) number() uint64 {
+	var n, shift uint64 = 0, 1
+	for r.err == nil {
+		if r.pos >= len(r.data) {
+			r.err = errTruncated
+			break
+		}
+		b := r.data[r.pos]
+		r.pos++
+		n += uint64(b&0x7F) * shift
+		if b&0x80 != 0 {
+			return n
+		}
+		shift <<= 7
+		n += shift
+		if shift > 1<<56 {
+			r.err = fmt.Errorf("invalid number at offset %d", r.pos)
+		}
+	}
+	return 0
+}
+
+// offset reads a signed relative offset, stored as a number with the sign in the low bit
+func (r *bpsReader) offset() int {
+	n := r.number()
+	if n&1 != 0 {
+		return -int(n >> 1)
+	}
+	return int(n >> 1)
+}
+
+// bytes reads n raw bytes
+func (r *bpsReader) bytes(n int) []byte {
+	if r.err != nil || r.pos+n > len(r.data) {
+		r.err = errTruncated
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *bpsReader) skip(n int) {
+	r.bytes(n)
+}
+
+// bpsWriter encodes a BPS patch
+type bpsWriter struct {
+	buf []byte
+}
+
+// number writes a variable-length number in the encoding read by bpsReader.number
+func (w *bpsWriter) number(n uint64) {
+	for {
+		b := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			w.buf = append(w.buf, b|0x80)
+			return
+		}
+		w.buf = append(w.buf, b)
+		n--
+	}
+}
@@ -0,0 +1,123 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// bpsPatch builds a BPS patch from encoded actions and adds the footer.
+func bpsPatch(source, target []byte, actions func(w *bpsWriter)) []byte {
+	w := &bpsWriter{}
+	w.buf = append(w.buf, bpsMagic...)
+	w.number(uint64(len(source)))
+	w.number(uint64(len(target)))
+	w.number(0)
+	actions(w)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(source))
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(target))
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(w.buf))
+	return w.buf
+}
+
+// offset encodes a signed relative offset
+func (w *bpsWriter) offset(n int) {
+	if n < 0 {
+		w.number(uint64(-n)<<1 | 1)
+	} else {
+		w.number(uint64(n) << 1)
+	}
+}
+
+func TestApplyBPSCopies(t *testing.T) {
+	source := []byte{1, 2, 3, 4, 5, 6}
+	target := []byte{5, 6, 1, 2, 9, 9, 9, 9, 9, 1, 2}
+	p := bpsPatch(source, target, func(w *bpsWriter) {
+		w.number(1<<2 | bpsSourceCopy) // 5, 6
+		w.offset(4)
+		w.number(1<<2 | bpsSourceCopy) // 1, 2, from 6 back to 0
+		w.offset(-6)
+		w.number(0<<2 | bpsTargetRead) // 9
+		w.buf = append(w.buf, 9)
+		w.number(3<<2 | bpsTargetCopy) // 9, 9, 9, 9, repeating the byte just written
+		w.offset(4)
+		w.number(1<<2 | bpsTargetCopy) // 1, 2, from 8 back to 2
+		w.offset(-6)
+	})
+	got, err := ApplyBPS(source, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Errorf("patched ROM = % X, want % X", got, target)
+	}
+}
+
+func TestApplyBPSChecksums(t *testing.T) {
+	source := []byte{1, 2, 3, 4}
+	target := []byte{1, 2, 7, 8, 9}
+	p := CreateBPS(source, target)
+
+	// refooter replaces a CRC of the footer and recomputes the patch's own
+	refooter := func(offset int, crc uint32) []byte {
+		q := append([]byte(nil), p...)
+		binary.LittleEndian.PutUint32(q[len(q)-bpsFooterSize+offset:], crc)
+		binary.LittleEndian.PutUint32(q[len(q)-4:], crc32.ChecksumIEEE(q[:len(q)-4]))
+		return q
+	}
+	corrupt := append([]byte(nil), p...)
+	corrupt[len(bpsMagic)+4] ^= 0xFF
+
+	tests := []struct {
+		name   string
+		source []byte
+		patch  []byte
+		want   string
+	}{
+		{"source", []byte{1, 2, 3, 5}, p, "ROM checksum"},
+		{"target", source, refooter(4, 0x12345678), "patched ROM checksum"},
+		{"patch", source, corrupt, "patch checksum"},
+		{"valid", source, p, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyBPS(tt.source, tt.patch)
+			if tt.want == "" {
+				if err != nil || !bytes.Equal(got, target) {
+					t.Errorf("ApplyBPS = % X, %v; want % X", got, err, target)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ApplyBPS error = %v, want a %s mismatch", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyBPSInvalid(t *testing.T) {
+	source := []byte{1, 2, 3, 4}
+	tests := []struct {
+		name    string
+		actions func(w *bpsWriter)
+		target  []byte
+	}{
+		{"write past the end", func(w *bpsWriter) { w.number(4<<2 | bpsSourceRead) }, []byte{1, 2, 3, 4}},
+		{"read past the source", func(w *bpsWriter) { w.number(4<<2 | bpsSourceRead) }, []byte{1, 2, 3, 4, 0}},
+		{"copy before the source", func(w *bpsWriter) { w.number(0<<2 | bpsSourceCopy); w.offset(-1) }, []byte{1}},
+		{"copy from unwritten output", func(w *bpsWriter) { w.number(0<<2 | bpsTargetCopy); w.offset(0) }, []byte{1}},
+		{"truncated data", func(w *bpsWriter) { w.number(3<<2 | bpsTargetRead); w.buf = append(w.buf, 1) }, []byte{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ApplyBPS(source, bpsPatch(source, tt.target, tt.actions)); err == nil {
+				t.Errorf("ApplyBPS = % X, want an error", got)
+			}
+		})
+	}
+	if _, err := ApplyBPS(source, []byte("BPS1")); err == nil {
+		t.Error("ApplyBPS accepted a patch without a footer")
+	}
+}
@@ -0,0 +1,114 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IPS patches are a "PATCH" header followed by records and an "EOF" marker.
+// A record is a 3-byte offset and a 2-byte size followed by the data; a size of 0
+// is a run of a 2-byte count and a single byte value. An optional 3-byte size after
+// the marker truncates the ROM (a common extension).
+const (
+	ipsMagic      = "PATCH"
+	ipsEOF        = "EOF"
+	ipsMaxOffset  = 0xFFFFFF
+	ipsMaxRecord  = 0xFFFF
+	ipsEOFAddress = 0x454F46 // The offset that would be read as the EOF marker
+)
+
+// errTruncated reports a patch that ends in the middle of a record
+var errTruncated = errors.New("truncated patch")
+
+// ApplyIPS patches a ROM with an IPS patch.
+func ApplyIPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < len(ipsMagic) || string(patch[:len(ipsMagic)]) != ipsMagic {
+		return nil, errors.New("not an IPS patch")
+	}
+	out := append([]byte(nil), rom...)
+	p := patch[len(ipsMagic):]
+	for {
+		if len(p) < 3 {
+			return nil, errTruncated
+		}
+		if string(p[:3]) == ipsEOF {
+			p = p[3:]
+			break
+		}
+		if len(p) < 5 {
+			return nil, errTruncated
+		}
+		offset := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		size := int(p[3])<<8 | int(p[4])
+		p = p[5:]
+
+		var data []byte
+		if size == 0 {
+			if len(p) < 3 {
+				return nil, errTruncated
+			}
+			count := int(p[0])<<8 | int(p[1])
+			data = make([]byte, count)
+			for i := range data {
+				data[i] = p[2]
+			}
+			p = p[3:]
+		} else {
+			if len(p) < size {
+				return nil, errTruncated
+			}
+			data = p[:size]
+			p = p[size:]
+		}
+
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	if len(p) >= 3 {
+		size := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		if size < len(out) {
+			out = out[:size]
+		}
+	}
+	return out, nil
+}
+
+// CreateIPS makes an IPS patch that turns original into modified.
+// A modified ROM that is shorter than the original is truncated with the EOF size extension.
+func CreateIPS(original, modified []byte) ([]byte, error) {
+	if len(modified) > ipsMaxOffset {
+		return nil, fmt.Errorf("ROM is too large for IPS (%d bytes)", len(modified))
+	}
+
+	out := []byte(ipsMagic)
+	for i := 0; i < len(modified); {
+		if i < len(original) && original[i] == modified[i] {
+			i++
+			continue
+		}
+		// A record can't start at the offset that reads as the EOF marker
+		start := i
+		if start == ipsEOFAddress {
+			start--
+		}
+		end := i
+		for end < len(modified) && end-start < ipsMaxRecord &&
+			(end >= len(original) || original[end] != modified[end]) {
+			end++
+		}
+		out = append(out, byte(start>>16), byte(start>>8), byte(start),
+			byte((end-start)>>8), byte(end-start))
+		out = append(out, modified[start:end]...)
+		i = end
+	}
+	out = append(out, ipsEOF...)
+
+	if len(modified) < len(original) {
+		size := len(modified)
+		out = append(out, byte(size>>16), byte(size>>8), byte(size))
+	}
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package patch
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestApplyIPS(t *testing.T) {
+	rom := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		name  string
+		patch string
+		want  []byte
+	}{
+		{"record", "PATCH\x00\x00\x02\x00\x02\xAA\xBBEOF", []byte{0, 1, 0xAA, 0xBB, 4, 5, 6, 7}},
+		{"run", "PATCH\x00\x00\x01\x00\x00\x00\x03\xCCEOF", []byte{0, 0xCC, 0xCC, 0xCC, 4, 5, 6, 7}},
+		{"run past the end", "PATCH\x00\x00\x06\x00\x00\x00\x04\xCCEOF", []byte{0, 1, 2, 3, 4, 5, 0xCC, 0xCC, 0xCC, 0xCC}},
+		{"gap past the end", "PATCH\x00\x00\x0A\x00\x01\xEEEOF", []byte{0, 1, 2, 3, 4, 5, 6, 7, 0, 0, 0xEE}},
+		{"truncation", "PATCH\x00\x00\x00\x00\x01\xFFEOF\x00\x00\x03", []byte{0xFF, 1, 2}},
+		{"size past the end", "PATCH" + "EOF\x00\x00\x10", rom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyIPS(rom, []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("patched ROM = % X, want % X", got, tt.want)
+			}
+		})
+	}
+	if rom[2] != 2 {
+		t.Error("ApplyIPS modified the original ROM")
+	}
+}
+
+func TestApplyIPSInvalid(t *testing.T) {
+	for _, patch := range []string{
+		"",
+		"PATCX",
+		"PATCH",
+		"PATCH\x00\x00\x02\x00",
+		"PATCH\x00\x00\x02\x00\x04\xAA\xBB",
+		"PATCH\x00\x00\x02\x00\x00\x00",
+	} {
+		if got, err := ApplyIPS([]byte{1, 2, 3}, []byte(patch)); err == nil {
+			t.Errorf("ApplyIPS(%q) = % X, want an error", patch, got)
+		}
+	}
+}
+
+// A record at 0x454F46 would start with the bytes "EOF" and end the patch,
+// so CreateIPS starts it a byte earlier.
+func TestCreateIPSEOFOffset(t *testing.T) {
+	original := make([]byte, ipsEOFAddress+4)
+	modified := append([]byte(nil), original...)
+	modified[ipsEOFAddress] = 0x42
+
+	p, err := CreateIPS(original, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := []byte{0x45, 0x4F, 0x45, 0x00, 0x02, 0x00, 0x42}
+	if want := append(append([]byte(ipsMagic), record...), ipsEOF...); !bytes.Equal(p, want) {
+		t.Fatalf("patch = % X, want % X", p, want)
+	}
+	got, err := ApplyIPS(original, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, modified) {
+		t.Error("patched ROM differs from the modified ROM")
+	}
+}
+
+func TestCreateIPSTooLarge(t *testing.T) {
+	if _, err := CreateIPS(nil, make([]byte, ipsMaxOffset+1)); err == nil {
+		t.Error("CreateIPS accepted a ROM larger than 16MB")
+	}
+}
@@ -0,0 +1,67 @@
+// Package patch applies and creates IPS and BPS patches for ROMs.
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Patch file extensions, in the order they are looked for next to a ROM
+var Extensions = []string{".bps", ".ips"}
+
+// Apply patches a ROM with an IPS or BPS patch, detected from the patch's header.
+// Parameters:
+//   - rom: The original ROM
+//   - patch: The contents of the patch file
+func Apply(rom, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte(bpsMagic)):
+		return ApplyBPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte(ipsMagic)):
+		return ApplyIPS(rom, patch)
+	}
+	return nil, errors.New("unknown patch format")
+}
+
+// Create makes a patch that turns original into modified.
+// Parameters:
+//   - format: "ips" or "bps"
+//   - original, modified: The ROM before and after the patch
+func Create(format string, original, modified []byte) ([]byte, error) {
+	switch format {
+	case "bps":
+		return CreateBPS(original, modified), nil
+	case "ips":
+		return CreateIPS(original, modified)
+	}
+	return nil, fmt.Errorf("unknown patch format %q", format)
+}
+
+// Find returns the patch file next to a ROM, with the ROM's name and a patch
+// extension (game.ch8 -> game.bps or game.ips), or "" if there is none.
+func Find(romPath string) string {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	for _, ext := range Extensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// ApplyFile patches a ROM with a patch file.
+func ApplyFile(rom []byte, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := Apply(rom, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return patched, nil
+}
@@ -0,0 +1,73 @@
+package patch
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// Pairs of original and modified ROMs every patch format must reproduce
+var roundTrips = []struct {
+	name               string
+	original, modified []byte
+}{
+	{"unchanged", []byte{1, 2, 3, 4}, []byte{1, 2, 3, 4}},
+	{"changed", []byte{1, 2, 3, 4, 5, 6}, []byte{1, 9, 3, 4, 8, 8}},
+	{"growth", []byte{1, 2, 3}, []byte{1, 2, 3, 4, 5, 6, 7}},
+	{"growth from empty", nil, []byte{0x12, 0x00}},
+	{"truncation", []byte{1, 2, 3, 4, 5, 6}, []byte{1, 7}},
+	{"truncation to empty", []byte{1, 2, 3}, []byte{}},
+	{"run", make([]byte, 16), bytes.Repeat([]byte{0xAA}, 300)},
+	{"long record", make([]byte, 0x10000+10), bytes.Repeat([]byte{0x55}, 0x10000+10)},
+}
+
+func TestCreateApply(t *testing.T) {
+	for _, format := range []string{"ips", "bps"} {
+		for _, tt := range roundTrips {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				p, err := Create(format, tt.original, tt.modified)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := Apply(tt.original, p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, tt.modified) {
+					t.Errorf("patched ROM = % X, want % X", got, tt.modified)
+				}
+			})
+		}
+	}
+}
+
+func TestCreateApplyRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		original := make([]byte, r.Intn(600))
+		r.Read(original)
+		modified := append([]byte(nil), original[:r.Intn(len(original)+1)]...)
+		modified = append(modified, make([]byte, r.Intn(100))...)
+		for j := r.Intn(50); j > 0 && len(modified) > 0; j-- {
+			modified[r.Intn(len(modified))] = byte(r.Intn(256))
+		}
+		for _, format := range []string{"ips", "bps"} {
+			p, err := Create(format, original, modified)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := Apply(original, p); err != nil || !bytes.Equal(got, modified) {
+				t.Fatalf("%s round trip %d: got % X, %v; want % X", format, i, got, err, modified)
+			}
+		}
+	}
+}
+
+func TestApplyUnknownFormat(t *testing.T) {
+	if _, err := Apply([]byte{1}, []byte("UPS1")); err == nil {
+		t.Error("Apply accepted a UPS patch")
+	}
+	if _, err := Create("ups", nil, nil); err == nil {
+		t.Error("Create accepted the ups format")
+	}
+}
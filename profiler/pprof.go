@@ -0,0 +1,231 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"time"
+)
+
+// Field numbers of the pprof profile.proto messages
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID      = 1
+	locationAddress = 3
+	locationLine    = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// WritePprof writes the profile in the gzipped protobuf format read by go tool pprof.
+// Every CHIP-8 address is a location of each routine that executed it, and
+// every routine a function. Line numbers are the addresses, so pprof -lines
+// shows the hot instructions.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	var table stringTable
+	table.index("")
+
+	var out protoBuffer
+	sampleType := protoBuffer{}
+	sampleType.int64(valueTypeType, table.index("instructions"))
+	sampleType.int64(valueTypeUnit, table.index("count"))
+	out.message(profileSampleType, &sampleType)
+
+	// Samples in a stable order, so the same session gives the same file
+	stacks := make([]stack, 0, len(p.counts))
+	for s := range p.counts {
+		stacks = append(stacks, s)
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		a, b := stacks[i], stacks[j]
+		for k := 0; k < min(a.depth, b.depth); k++ {
+			if a.frames[k] != b.frames[k] {
+				return a.frames[k].less(b.frames[k])
+			}
+		}
+		return a.depth < b.depth
+	})
+
+	// Every frame is a location, numbered in address order from 1
+	seen := make(map[frame]bool)
+	var frames []frame
+	for _, s := range stacks {
+		for _, f := range s.frames[:s.depth] {
+			if !seen[f] {
+				seen[f] = true
+				frames = append(frames, f)
+			}
+		}
+	}
+	sort.Slice(frames, func(i, j int) bool { return frames[i].less(frames[j]) })
+	locations := make(map[frame]uint64, len(frames))
+	for i, f := range frames {
+		locations[f] = uint64(i) + 1
+	}
+
+	for _, s := range stacks {
+		var sample protoBuffer
+		ids := make([]uint64, s.depth)
+		for i, f := range s.frames[:s.depth] {
+			ids[i] = locations[f]
+		}
+		sample.packed(sampleLocationID, ids)
+		sample.packed(sampleValue, []uint64{uint64(p.counts[s])})
+		out.message(profileSample, &sample)
+	}
+
+	// Function ids are the routine entry + 1
+	routines := make(map[uint16]bool)
+	for _, f := range frames {
+		routines[f.routine] = true
+
+		var line protoBuffer
+		line.uint64(lineFunctionID, uint64(f.routine)+1)
+		line.int64(lineLine, int64(f.pc))
+		var location protoBuffer
+		location.uint64(locationID, locations[f])
+		location.uint64(locationAddress, uint64(f.pc))
+		location.message(locationLine, &line)
+		out.message(profileLocation, &location)
+	}
+
+	entries := make([]uint16, 0, len(routines))
+	for entry := range routines {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i] < entries[j] })
+	for _, entry := range entries {
+		name := table.index(p.routineName(entry))
+		var function protoBuffer
+		function.uint64(functionID, uint64(entry)+1)
+		function.int64(functionName, name)
+		function.int64(functionSystemName, name)
+		function.int64(functionFilename, table.index(p.ROM))
+		function.int64(functionStartLine, int64(entry))
+		out.message(profileFunction, &function)
+	}
+
+	periodType := protoBuffer{}
+	periodType.int64(valueTypeType, table.index("instructions"))
+	periodType.int64(valueTypeUnit, table.index("count"))
+	out.int64(profileTimeNanos, p.start.UnixNano())
+	out.int64(profileDurationNanos, int64(time.Since(p.start)))
+	out.message(profilePeriodType, &periodType)
+	out.int64(profilePeriod, 1)
+
+	// The string table goes last, once every string has been indexed
+	for _, s := range table.strings {
+		out.string(profileStringTable, s)
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(out.buf); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// less orders frames by address, then by routine
+func (f frame) less(g frame) bool {
+	if f.pc != g.pc {
+		return f.pc < g.pc
+	}
+	return f.routine < g.routine
+}
+
+// stringTable assigns indices to the strings of a profile
+type stringTable struct {
+	strings []string
+	indices map[string]int64
+}
+
+func (t *stringTable) index(s string) int64 {
+	if t.indices == nil {
+		t.indices = make(map[string]int64)
+	}
+	if i, ok := t.indices[s]; ok {
+		return i
+	}
+	i := int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.indices[s] = i
+	return i
+}
+
+// protoBuffer encodes protobuf messages.
+// Only the wire types used by profile.proto are supported.
+type protoBuffer struct {
+	buf []byte
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.buf = append(b.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	b.buf = append(b.buf, byte(v))
+}
+
+func (b *protoBuffer) tag(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// uint64 writes a varint field, omitting zero values like proto3 does
+func (b *protoBuffer) uint64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, 0)
+	b.varint(v)
+}
+
+func (b *protoBuffer) int64(field int, v int64) {
+	b.uint64(field, uint64(v))
+}
+
+// bytes writes a length-delimited field
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.tag(field, 2)
+	b.varint(uint64(len(data)))
+	b.buf = append(b.buf, data...)
+}
+
+// string writes a string field; empty strings are kept, since the string table starts with one
+func (b *protoBuffer) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protoBuffer) message(field int, m *protoBuffer) {
+	b.bytes(field, m.buf)
+}
+
+// packed writes a repeated varint field in packed encoding
+func (b *protoBuffer) packed(field int, values []uint64) {
+	var data protoBuffer
+	for _, v := range values {
+		data.varint(v)
+	}
+	b.bytes(field, data.buf)
+}
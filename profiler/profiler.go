@@ -0,0 +1,131 @@
+// Package profiler attributes executed CHIP-8 instructions to subroutines and
+// exports the call trees as pprof profiles.
+package profiler
+
+import (
+	"fmt"
+	"go-r8t/cpu"
+	"sort"
+	"time"
+)
+
+// maxDepth is the deepest call stack a sample can have: the CPU's 16 stack levels and the current PC
+const maxDepth = 17
+
+// frame is an address on a call stack and the routine executing it.
+// The same address can belong to several routines, e.g. a tail they share.
+type frame struct {
+	pc      uint16
+	routine uint16
+}
+
+// stack is a call stack, innermost first: the current PC followed by the call sites
+type stack struct {
+	frames [maxDepth]frame
+	depth  int
+}
+
+// Profiler counts executed instructions per call stack.
+// The counts are exact: every instruction is sampled.
+type Profiler struct {
+	ROM    string          // Name of the program, shown as the source file in pprof
+	entry  uint16          // Entry point of the program, which is not reached by a CALL
+	counts map[stack]int64 // Instructions executed per call stack
+	start  time.Time
+}
+
+// New creates an empty profiler.
+// Parameters:
+//   - rom: The name of the profiled program
+//   - entry: The address the program starts at
+func New(rom string, entry uint16) *Profiler {
+	return &Profiler{
+		ROM:    rom,
+		entry:  entry,
+		counts: make(map[stack]int64),
+		start:  time.Now(),
+	}
+}
+
+// Sample records the instruction the CPU is about to execute.
+// It must be called before every Step.
+func (p *Profiler) Sample(chip8 *cpu.CPU) {
+	if chip8.Exited {
+		return
+	}
+
+	// The routine each frame belongs to is the target of the call below it.
+	// cpu.Stack holds the addresses of the CALL instructions, outermost first.
+	var s stack
+	depth := min(int(chip8.SP), len(chip8.Stack))
+	s.depth = depth + 1
+	routine := p.entry
+	for i := 0; i < depth; i++ {
+		site := chip8.Stack[i]
+		s.frames[depth-i] = frame{site, routine}
+		routine = callTarget(chip8, site)
+	}
+	s.frames[0] = frame{chip8.PC, routine}
+	p.counts[s]++
+}
+
+// callTarget returns the subroutine called by the instruction at address
+func callTarget(chip8 *cpu.CPU, address uint16) uint16 {
+	if int(address)+1 >= len(chip8.Memory) {
+		return address
+	}
+	opcode := uint16(chip8.Read8(address))<<8 | uint16(chip8.Read8(address+1))
+	return opcode & 0x0FFF
+}
+
+// Routine is the number of instructions executed in a subroutine.
+type Routine struct {
+	Entry uint16
+	Self  int64 // Instructions executed in the routine itself
+	Total int64 // Instructions executed in the routine and the routines it called
+}
+
+// Routines returns the profiled routines, most expensive first.
+func (p *Profiler) Routines() []Routine {
+	byEntry := make(map[uint16]*Routine)
+	get := func(entry uint16) *Routine {
+		r, ok := byEntry[entry]
+		if !ok {
+			r = &Routine{Entry: entry}
+			byEntry[entry] = r
+		}
+		return r
+	}
+
+	for s, n := range p.counts {
+		get(s.frames[0].routine).Self += n
+		// Recursive routines only count once per sample
+		seen := make(map[uint16]bool)
+		for _, f := range s.frames[:s.depth] {
+			if !seen[f.routine] {
+				seen[f.routine] = true
+				get(f.routine).Total += n
+			}
+		}
+	}
+
+	routines := make([]Routine, 0, len(byEntry))
+	for _, r := range byEntry {
+		routines = append(routines, *r)
+	}
+	sort.Slice(routines, func(i, j int) bool {
+		if routines[i].Total != routines[j].Total {
+			return routines[i].Total > routines[j].Total
+		}
+		return routines[i].Entry < routines[j].Entry
+	})
+	return routines
+}
+
+// routineName returns the function name pprof shows for a routine
+func (p *Profiler) routineName(entry uint16) string {
+	if entry == p.entry {
+		return "main"
+	}
+	return fmt.Sprintf("sub_%03X", entry)
+}
@@ -0,0 +1,234 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"go-r8t/cpu"
+	"io"
+	"testing"
+)
+
+// Program with nested calls and a tail shared by two routines:
+//
+//	200: 2206  CALL 206
+//	202: 220C  CALL 20C
+//	204: 1204  JP 204
+//	206: 2214  CALL 214  ; sub_206
+//	208: 1210  JP 210
+//	20A: 0000
+//	20C: 6002  LD V0, 2  ; sub_20C
+//	20E: 1210  JP 210
+//	210: 6103  LD V1, 3  ; tail of sub_206 and sub_20C
+//	212: 00EE  RET
+//	214: 00EE  RET       ; sub_214
+var nested = []byte{
+	0x22, 0x06, 0x22, 0x0C, 0x12, 0x04, 0x22, 0x14, 0x12, 0x10, 0x00, 0x00,
+	0x60, 0x02, 0x12, 0x10, 0x61, 0x03, 0x00, 0xEE, 0x00, 0xEE,
+}
+
+// Instructions executed by 13 steps of the program, per routine
+var nestedRoutines = map[uint16]Routine{
+	0x200: {Entry: 0x200, Self: 4, Total: 13}, // 200, 202, 204, 204
+	0x206: {Entry: 0x206, Self: 4, Total: 5},  // 206, 208, 210, 212 and sub_214
+	0x20C: {Entry: 0x20C, Self: 4, Total: 4},  // 20C, 20E, 210, 212
+	0x214: {Entry: 0x214, Self: 1, Total: 1},  // 214
+}
+
+// profileNested runs the program under the profiler.
+func profileNested(t *testing.T) *Profiler {
+	t.Helper()
+	chip8 := cpu.NewCPU()
+	if err := chip8.LoadProgram(nested); err != nil {
+		t.Fatal(err)
+	}
+	p := New("nested.ch8", chip8.PC)
+	for i := 0; i < 13; i++ {
+		p.Sample(chip8)
+		chip8.Step()
+	}
+	return p
+}
+
+func TestRoutines(t *testing.T) {
+	routines := profileNested(t).Routines()
+	if len(routines) != len(nestedRoutines) {
+		t.Fatalf("got %d routines, want %d: %+v", len(routines), len(nestedRoutines), routines)
+	}
+	for i, r := range routines {
+		if want := nestedRoutines[r.Entry]; r != want {
+			t.Errorf("routine %03X = %+v, want %+v", r.Entry, r, want)
+		}
+		if i > 0 && r.Total > routines[i-1].Total {
+			t.Errorf("routine %03X is listed after a cheaper one", r.Entry)
+		}
+	}
+}
+
+func TestRecursion(t *testing.T) {
+	// 200: 2202 CALL 202, calling itself forever
+	chip8 := cpu.NewCPU()
+	if err := chip8.LoadProgram([]byte{0x22, 0x02, 0x22, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	p := New("recursion.ch8", chip8.PC)
+	for i := 0; i < 5; i++ {
+		p.Sample(chip8)
+		chip8.Step()
+	}
+	want := []Routine{{Entry: 0x200, Self: 1, Total: 5}, {Entry: 0x202, Self: 4, Total: 4}}
+	if got := p.Routines(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("routines = %+v, want %+v", got, want)
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	var buf bytes.Buffer
+	if err := profileNested(t).WritePprof(&buf); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decode the samples, locations, functions and string table
+	type sample struct {
+		locations []uint64
+		value     uint64
+	}
+	var samples []sample
+	locationFunction := make(map[uint64]uint64)
+	functionNames := make(map[uint64]uint64)
+	var table []string
+	for _, f := range decodeProto(t, data) {
+		switch f.number {
+		case profileSample:
+			var s sample
+			for _, g := range decodeProto(t, f.data) {
+				switch g.number {
+				case sampleLocationID:
+					s.locations = decodePacked(t, g.data)
+				case sampleValue:
+					s.value = decodePacked(t, g.data)[0]
+				}
+			}
+			samples = append(samples, s)
+		case profileLocation:
+			var id, function uint64
+			for _, g := range decodeProto(t, f.data) {
+				switch g.number {
+				case locationID:
+					id = g.value
+				case locationLine:
+					for _, h := range decodeProto(t, g.data) {
+						if h.number == lineFunctionID {
+							function = h.value
+						}
+					}
+				}
+			}
+			locationFunction[id] = function
+		case profileFunction:
+			var id, name uint64
+			for _, g := range decodeProto(t, f.data) {
+				switch g.number {
+				case functionID:
+					id = g.value
+				case functionName:
+					name = g.value
+				}
+			}
+			functionNames[id] = name
+		case profileStringTable:
+			table = append(table, string(f.data))
+		}
+	}
+
+	// Count the instructions of each function like pprof does
+	self := make(map[string]uint64)
+	total := make(map[string]uint64)
+	for _, s := range samples {
+		name := func(location uint64) string {
+			function, ok := locationFunction[location]
+			if !ok {
+				t.Fatalf("sample refers to unknown location %d", location)
+			}
+			return table[functionNames[function]]
+		}
+		self[name(s.locations[0])] += s.value
+		seen := make(map[string]bool)
+		for _, location := range s.locations {
+			if n := name(location); !seen[n] {
+				seen[n] = true
+				total[n] += s.value
+			}
+		}
+	}
+	routineNames := map[uint16]string{0x200: "main", 0x206: "sub_206", 0x20C: "sub_20C", 0x214: "sub_214"}
+	for entry, want := range nestedRoutines {
+		n := routineNames[entry]
+		if self[n] != uint64(want.Self) || total[n] != uint64(want.Total) {
+			t.Errorf("%s: self %d, total %d; want %d, %d", n, self[n], total[n], want.Self, want.Total)
+		}
+	}
+}
+
+// protoField is a decoded protobuf field: a varint value or length-delimited data.
+type protoField struct {
+	number int
+	value  uint64
+	data   []byte
+}
+
+// decodeProto splits a protobuf message into its fields.
+func decodeProto(t *testing.T, buf []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(buf) > 0 {
+		key, n := readVarint(t, buf)
+		buf = buf[n:]
+		f := protoField{number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.value, n = readVarint(t, buf)
+			buf = buf[n:]
+		case 2:
+			size, n := readVarint(t, buf)
+			buf = buf[n:]
+			f.data, buf = buf[:size], buf[size:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// decodePacked decodes a packed repeated varint field.
+func decodePacked(t *testing.T, buf []byte) []uint64 {
+	t.Helper()
+	var values []uint64
+	for len(buf) > 0 {
+		v, n := readVarint(t, buf)
+		values = append(values, v)
+		buf = buf[n:]
+	}
+	return values
+}
+
+func readVarint(t *testing.T, buf []byte) (uint64, int) {
+	t.Helper()
+	var v uint64
+	for i, b := range buf {
+		v |= uint64(b&0x7F) << (7 * i)
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	t.Fatal("truncated varint")
+	return 0, 0
+}
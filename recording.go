@@ -0,0 +1,10 @@
+package main
+
+import "go-r8t/inputlog"
+
+// Input of the sessions, recorded by the frontends and replayed by the headless commands
+var (
+	recordPath     string             // Path the input of each session is written to, empty to not record
+	activeRecorder *inputlog.Recorder // Recorder of the current session, if recording
+	inputReplay    *inputlog.Player   // Input replayed in each session, if any
+)
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"go-r8t/chip8"
+	"go-r8t/cpu"
+	"go-r8t/inputlog"
+	"go-r8t/keymap"
+	"go-r8t/library"
+	"go-r8t/patch"
+	"go-r8t/profiler"
+	"go-r8t/romdb"
+	"image/color"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ROM database used to identify ROMs
+var romDatabase *romdb.Database
+
+// Patch files given on the command line, keyed by ROM path.
+// They take precedence over patches found next to the ROM.
+var romPatches = make(map[string]string)
+
+// Play history shown by the launcher
+var playHistory *library.History
+
+// Settings of the loaded ROM
+var (
+	currentROMFile string                         // File name of the ROM, used for per-ROM settings
+	currentProgram []byte                         // Contents of the ROM file
+	currentROMHash string                         // SHA-1 of the ROM, used for cheats
+	tickrate       = cpu.PlatformCHIP8.Tickrate() // Instructions executed per frame
+	romPalette     []color.RGBA                   // Palette from the ROM database, if any
+	romDefaults    *keymap.Profile                // Key bindings from the ROM database, if any
+)
+
+// Options of the ROMs, set by the -platform, -memory, -font and -font-address
+// flags. Flags given later take precedence, since they are applied in order.
+var romOptions []chip8.Option
+
+// Usage of the -platform flag
+const platformUsage = "`platform` to run the ROM on instead of the database's or the detected one: chip8, modernchip8, schip, xochip, chip8hires, eti660 or dream6800"
+
+// setPlatform parses the -platform flag
+func setPlatform(name string) error {
+	platform, err := cpu.ParsePlatform(name)
+	if err != nil {
+		return err
+	}
+	romOptions = append(romOptions, chip8.WithPlatform(platform))
+	return nil
+}
+
+// Usage of the -memory flag
+const memoryPolicyUsage = "`policy` for addresses past the end of memory: wrap, clamp or fault (default wrap)"
+
+// setMemoryPolicy parses the -memory flag
+func setMemoryPolicy(name string) error {
+	policy, err := cpu.ParseMemoryPolicy(name)
+	if err != nil {
+		return err
+	}
+	romOptions = append(romOptions, chip8.WithMemoryPolicy(policy))
+	return nil
+}
+
+// Usage of the -font flag
+var fontUsage = "font to use instead of the platform's: " + strings.Join(cpu.FontNames(), ", ") + ", or a font `file`"
+
+// setFont parses the -font flag: the name of a built-in font or a font file
+func setFont(value string) error {
+	font, err := cpu.ParseFont(value)
+	if err != nil {
+		data, readErr := os.ReadFile(value)
+		if os.IsNotExist(readErr) {
+			return err
+		} else if readErr != nil {
+			return readErr
+		}
+		if font, err = cpu.ReadFont(filepath.Base(value), data); err != nil {
+			return err
+		}
+	}
+	romOptions = append(romOptions, chip8.WithFont(font))
+	return nil
+}
+
+// setFontAddress parses the -font-address flag
+func setFontAddress(value string) error {
+	address, err := strconv.ParseUint(value, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid address %q", value)
+	}
+	romOptions = append(romOptions, chip8.WithFontAddress(uint16(address)))
+	return nil
+}
+
+// Keyboard keys bound to the logical controls of the ROM database
+var databaseControlKeys = map[string]string{
+	"up":    "up",
+	"down":  "down",
+	"left":  "left",
+	"right": "right",
+	"a":     "space",
+}
+
+// LoadROMDatabase loads the embedded ROM database and merges the local database file into it
+func LoadROMDatabase(localPath string) error {
+	db, err := romdb.Load()
+	if err != nil {
+		return err
+	}
+	if err := db.MergeFile(localPath); err != nil {
+		return err
+	}
+	romDatabase = db
+	return nil
+}
+
+// readROM reads a ROM file and applies its patch, if it has one.
+// It returns the ROM as stored on disk, the patched ROM and the path of the applied patch.
+func readROM(path string) (original, program []byte, patchPath string, err error) {
+	original, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	patchPath, ok := romPatches[path]
+	if !ok {
+		patchPath = patch.Find(path)
+	}
+	if patchPath == "" {
+		return original, original, "", nil
+	}
+	program, err = patch.ApplyFile(original, patchPath)
+	return original, program, patchPath, err
+}
+
+// loadROM reads a ROM file from disk and loads it into the CPU's memory.
+// A patch from romPatches, or an IPS/BPS file next to the ROM, is applied first.
+// The ROM is set up with chip8.Setup, like in the chip8 package, and
+// identified by its unpatched contents: if it is in the database, its
+// platform, quirks, font, tickrate, palette and keys are applied. Otherwise
+// its platform is guessed from its contents and file extension. The
+// -platform flag overrides both.
+func loadROM(c *cpu.CPU, path string) error {
+	original, program, patchPath, err := readROM(path)
+	if err != nil {
+		return err
+	}
+	if patchPath != "" {
+		SetStatus("Applied patch " + filepath.Base(patchPath))
+	}
+	currentROMFile = filepath.Base(path)
+	currentProgram = program
+	currentROMHash = romdb.Hash(original)
+	activeCheats = cheatConfig.ROMs[currentROMHash]
+	cheatSearch = nil
+	if err := loadFlags(c); err != nil {
+		return err
+	}
+
+	opts := append([]chip8.Option{chip8.WithDatabase(romDatabase)}, romOptions...)
+	settings, err := chip8.Setup(c, currentROMFile, original, program, opts...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	tickrate = settings.Tickrate
+	romPalette = settings.Palette
+	romDefaults = controlBindings(settings.Keys)
+	name := currentROMFile
+	if settings.Name != "" {
+		name = settings.Name
+	}
+	SetCurrentROM(name)
+	return nil
+}
+
+// bootROM creates a fresh CPU running the ROM at path.
+// It applies the ROM's settings and key bindings, and records it in the play history.
+func bootROM(path string) (*cpu.CPU, error) {
+	chip8 := cpu.NewCPU()
+	if err := loadROM(chip8, path); err != nil {
+		return nil, err
+	}
+	if err := reloadKeymap(); err != nil {
+		return nil, err
+	}
+	startSession(chip8)
+	if playHistory != nil {
+		if err := playHistory.MarkPlayed(path); err != nil {
+			SetStatus("Failed to save play history: " + err.Error())
+		}
+	}
+	return chip8, nil
+}
+
+// startSession is called when a ROM has been loaded.
+// It starts recording the coverage, profile and input of the session, and
+// replaying the input log, if requested. The CPU is seeded with the log's
+// seed, or a new one that the recorded log saves.
+func startSession(chip8 *cpu.CPU) {
+	if coveragePrefix != "" {
+		chip8.Coverage = cpu.NewCoverage()
+	}
+	activeProfiler = nil
+	if profilePath != "" {
+		activeProfiler = profiler.New(currentROMFile, chip8.PC)
+	}
+
+	// A replayed log runs with the seed it was recorded with
+	seed := rand.Uint64()
+	if inputReplay != nil {
+		inputReplay.Rewind()
+		if logSeed, ok := inputReplay.Seed(); ok {
+			seed = logSeed
+		}
+	}
+	chip8.SeedRandom(seed)
+	activeRecorder = nil
+	if recordPath != "" {
+		activeRecorder = inputlog.NewRecorder(seed)
+	}
+}
+
+// endSession is called when the player leaves a ROM, by quitting or returning to the launcher.
+// It saves the session's coverage, profile and input, if they were recorded.
+func endSession(chip8 *cpu.CPU) error {
+	if chip8.Coverage != nil {
+		if err := writeCoverage(coveragePrefix, currentProgram, chip8.Platform, chip8.Coverage); err != nil {
+			return fmt.Errorf("failed to write coverage: %w", err)
+		}
+	}
+	if activeProfiler != nil {
+		if err := writeProfile(profilePath, activeProfiler); err != nil {
+			return fmt.Errorf("failed to write profile: %w", err)
+		}
+	}
+	if activeRecorder != nil {
+		if err := activeRecorder.Save(recordPath); err != nil {
+			return fmt.Errorf("failed to write input log: %w", err)
+		}
+	}
+	return nil
+}
+
+// controlBindings converts the logical controls of a database entry into key and gamepad bindings
+func controlBindings(controls map[string]int) *keymap.Profile {
+	if len(controls) == 0 {
+		return nil
+	}
+
+	profile := &keymap.Profile{Gamepad: make(keymap.GamepadBindings)}
+	for control, key := range controls {
+		if key < 0 || key > 0xF {
+			continue
+		}
+		profile.Gamepad[control] = uint8(key)
+		if name, ok := databaseControlKeys[control]; ok {
+			profile.Keys[key] = append(profile.Keys[key], name)
+		}
+	}
+	return profile
+}
+
+// runFrame replays or records the frame's input, reapplies the frozen cheats
+// and runs one frame with chip8.ExecuteFrame, like the chip8 package. A memory
+// fault is shown in the status line.
+// It is called once per frame by both frontends and the headless commands.
+func runFrame(c *cpu.CPU) {
+	if inputReplay != nil {
+		inputReplay.Apply(c)
+	}
+	if activeRecorder != nil {
+		activeRecorder.Record(c)
+	}
+	activeCheats.Apply(c)
+	var step func(*cpu.CPU)
+	if activeProfiler != nil {
+		step = activeProfiler.Sample
+	}
+	chip8.ExecuteFrame(c, tickrate, step)
+	persistFlags(c)
+
+	// The CPU stops at a memory fault; keep it on screen
+	if c.Fault != nil {
+		SetStatus(c.Fault.Error())
+	}
+}
@@ -0,0 +1,155 @@
+// Package romdb identifies CHIP-8 programs by the SHA-1 hash of their ROM.
+//
+// The database uses the programs.json format of the community chip-8-database
+// project (https://github.com/chip-8/chip-8-database): a list of programs, each
+// with its ROMs keyed by SHA-1 hash. programs.json is embedded in the binary,
+// and a local file in the same format can add or replace entries.
+//
+// The source tree ships programs.json as an empty list; go generate downloads
+// the community database into it.
+package romdb
+
+import (
+	"crypto/sha1"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-r8t/userdata"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+//go:generate curl -sSfL -o programs.json https://raw.githubusercontent.com/chip-8/chip-8-database/master/database/programs.json
+
+//go:embed programs.json
+var embedded embed.FS
+
+// Program describes a CHIP-8 program and the known ROMs for it.
+type Program struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Release     string         `json:"release,omitempty"`
+	Authors     []string       `json:"authors,omitempty"`
+	ROMs        map[string]ROM `json:"roms"` // Keyed by lowercase SHA-1 hash
+}
+
+// ROM describes one ROM file of a program and how to run it.
+type ROM struct {
+	File            string            `json:"file,omitempty"`
+	Platforms       []string          `json:"platforms,omitempty"`       // Compatible platforms, preferred first
+	QuirkyPlatforms map[string]Quirks `json:"quirkyPlatforms,omitempty"` // Platforms that need non-default quirks
+	Tickrate        int               `json:"tickrate,omitempty"`        // Instructions per frame
+	StartAddress    int               `json:"startAddress,omitempty"`
+	Keys            map[string]int    `json:"keys,omitempty"` // Logical controls (up, down, left, right, a, b) to CHIP-8 keys
+	Colors          *Colors           `json:"colors,omitempty"`
+}
+
+// Quirks overrides the default quirks of a platform. Nil fields keep the default.
+type Quirks struct {
+	Shift                 *bool `json:"shift,omitempty"`
+	MemoryIncrementByX    *bool `json:"memoryIncrementByX,omitempty"`
+	MemoryLeaveIUnchanged *bool `json:"memoryLeaveIUnchanged,omitempty"`
+	Wrap                  *bool `json:"wrap,omitempty"`
+	Jump                  *bool `json:"jump,omitempty"`
+	VBlank                *bool `json:"vblank,omitempty"`
+	Logic                 *bool `json:"logic,omitempty"`
+}
+
+// Colors is the palette a program was designed for, as "#RRGGBB" strings.
+type Colors struct {
+	Pixels  []string `json:"pixels,omitempty"` // Background first, then the foreground color(s)
+	Buzzer  string   `json:"buzzer,omitempty"`
+	Silence string   `json:"silence,omitempty"`
+}
+
+// Entry is the result of a database lookup.
+type Entry struct {
+	SHA1    string
+	Program *Program
+	ROM     *ROM
+}
+
+// Database maps ROM hashes to programs.
+type Database struct {
+	entries map[string]Entry
+}
+
+// Load returns the embedded database.
+func Load() (*Database, error) {
+	data, err := embedded.ReadFile("programs.json")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads a database in the chip-8-database programs.json format.
+func Parse(data []byte) (*Database, error) {
+	var programs []*Program
+	if err := json.Unmarshal(data, &programs); err != nil {
+		return nil, err
+	}
+
+	db := &Database{entries: make(map[string]Entry)}
+	for _, program := range programs {
+		for hash, rom := range program.ROMs {
+			hash = strings.ToLower(hash)
+			db.entries[hash] = Entry{SHA1: hash, Program: program, ROM: &rom}
+		}
+	}
+	return db, nil
+}
+
+// DefaultOverridePath returns the location of the local database file.
+func DefaultOverridePath() string {
+	return userdata.Path("programs.json")
+}
+
+// MergeFile adds the entries of a local database file, replacing entries with the same hash.
+// A missing file is not an error.
+func (db *Database) MergeFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	local, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for hash, entry := range local.entries {
+		db.entries[hash] = entry
+	}
+	return nil
+}
+
+// Lookup finds the database entry for a ROM hash.
+func (db *Database) Lookup(sha1 string) (Entry, bool) {
+	entry, ok := db.entries[strings.ToLower(sha1)]
+	return entry, ok
+}
+
+// Len returns the number of ROMs in the database.
+func (db *Database) Len() int {
+	return len(db.entries)
+}
+
+// Hash returns the lowercase hexadecimal SHA-1 hash of a ROM.
+func Hash(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
+
+// Name returns the program title followed by its authors, e.g. "Pong by Paul Vervalin".
+func (e Entry) Name() string {
+	if len(e.Program.Authors) == 0 {
+		return e.Program.Title
+	}
+	return e.Program.Title + " by " + strings.Join(e.Program.Authors, ", ")
+}
@@ -0,0 +1,121 @@
+package romdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testDatabase has two programs, one with two ROMs. Hashes are in mixed case,
+// as some databases write them.
+const testDatabase = `[
+	{
+		"title": "Pong",
+		"authors": ["Paul Vervalin"],
+		"roms": {
+			"AAAA000000000000000000000000000000000001": {"file": "pong.ch8", "platforms": ["originalChip8"]},
+			"aaaa000000000000000000000000000000000002": {"file": "pong2.ch8", "platforms": ["modernChip8"]}
+		}
+	},
+	{
+		"title": "Untitled",
+		"roms": {"bbbb000000000000000000000000000000000001": {"platforms": ["xochip"]}}
+	}
+]`
+
+func TestParse(t *testing.T) {
+	db, err := Parse([]byte(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", db.Len())
+	}
+	tests := []struct {
+		hash, file, name string
+	}{
+		{"aaaa000000000000000000000000000000000001", "pong.ch8", "Pong by Paul Vervalin"},
+		{"AAAA000000000000000000000000000000000002", "pong2.ch8", "Pong by Paul Vervalin"},
+		{"bbbb000000000000000000000000000000000001", "", "Untitled"},
+	}
+	for _, tt := range tests {
+		entry, ok := db.Lookup(tt.hash)
+		if !ok {
+			t.Errorf("Lookup(%s) found nothing", tt.hash)
+			continue
+		}
+		if entry.ROM.File != tt.file || entry.Name() != tt.name {
+			t.Errorf("Lookup(%s) = %s, %q; want %s, %q", tt.hash, entry.ROM.File, entry.Name(), tt.file, tt.name)
+		}
+	}
+	if _, ok := db.Lookup("cccc000000000000000000000000000000000001"); ok {
+		t.Error("Lookup found a ROM that isn't in the database")
+	}
+
+	if _, err := Parse([]byte(`{"title": "not a list"}`)); err == nil {
+		t.Error("Parse accepted an object")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	// The embedded database parses, whether it is empty or downloaded
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeFile(t *testing.T) {
+	db, err := Parse([]byte(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	// A missing local database is not an error
+	if err := db.MergeFile(filepath.Join(dir, "missing.json")); err != nil {
+		t.Errorf("MergeFile of a missing file: %v", err)
+	}
+
+	// Local entries replace the ones with the same hash and add new ones
+	local := filepath.Join(dir, "programs.json")
+	if err := os.WriteFile(local, []byte(`[{
+		"title": "My Pong",
+		"roms": {
+			"aaaa000000000000000000000000000000000001": {"platforms": ["superchip"]},
+			"dddd000000000000000000000000000000000001": {}
+		}
+	}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MergeFile(local); err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 4 {
+		t.Errorf("Len() = %d after merging, want 4", db.Len())
+	}
+	for hash, want := range map[string]string{
+		"aaaa000000000000000000000000000000000001": "My Pong",
+		"aaaa000000000000000000000000000000000002": "Pong",
+		"dddd000000000000000000000000000000000001": "My Pong",
+	} {
+		if entry, ok := db.Lookup(hash); !ok || entry.Program.Title != want {
+			t.Errorf("Lookup(%s) = %v, %t; want %s", hash, entry.Program, ok, want)
+		}
+	}
+
+	// An invalid file is reported with its path
+	if err := os.WriteFile(local, []byte("["), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MergeFile(local); err == nil || !strings.Contains(err.Error(), local) {
+		t.Errorf("MergeFile of an invalid file returned %v, want an error naming %s", err, local)
+	}
+}
+
+func TestHash(t *testing.T) {
+	// SHA-1 of "abc"
+	if got, want := Hash([]byte("abc")), "a9993e364706816aba3e25717850c26c9cd0d89d"; got != want {
+		t.Errorf("Hash(abc) = %s, want %s", got, want)
+	}
+}
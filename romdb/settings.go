@@ -0,0 +1,123 @@
+package romdb
+
+import (
+	"fmt"
+	"go-r8t/cpu"
+	"image/color"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ROM file extensions and the platform they imply for ROMs that are not in the database
+var extensions = map[string]cpu.Platform{
+	".ch8": cpu.PlatformCHIP8,
+	".sc8": cpu.PlatformSCHIP,
+	".xo8": cpu.PlatformXOCHIP,
+}
+
+// IsROMFile reports whether a file name has one of the CHIP-8 ROM extensions.
+func IsROMFile(name string) bool {
+	_, ok := extensions[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// Settings are the emulator settings derived from a database entry.
+type Settings struct {
+	Name     string // Title and authors of the program; empty if it is not in the database
+	Platform cpu.Platform
+	Quirks   cpu.Quirks
+	Tickrate int            // Instructions per frame
+	Palette  []color.RGBA   // Background first, then foreground; nil if the program has no palette
+	Keys     map[string]int // Logical controls (up, down, left, right, a, b) to CHIP-8 keys
+}
+
+// Settings picks the first platform of the ROM that the emulator supports
+// and applies the ROM's quirks, tickrate, palette and keys on top of it.
+// CHIP-8 ROMs that start at 0x600 run on the ETI-660.
+func (e Entry) Settings() Settings {
+	platform, platformID := cpu.PlatformCHIP8, ""
+	for _, id := range e.ROM.Platforms {
+		if p, err := cpu.ParsePlatform(id); err == nil {
+			platform, platformID = p, id
+			break
+		}
+	}
+	// The database has no ETI-660 platform, but its ROMs start at 0x600
+	if platform == cpu.PlatformCHIP8 && e.ROM.StartAddress == int(cpu.PlatformETI660.Descriptor().LoadAddress) {
+		platform = cpu.PlatformETI660
+	}
+
+	settings := Settings{
+		Name:     e.Name(),
+		Platform: platform,
+		Quirks:   platform.Quirks(),
+		Tickrate: e.ROM.Tickrate,
+		Keys:     e.ROM.Keys,
+	}
+	if settings.Tickrate <= 0 {
+		settings.Tickrate = platform.Tickrate()
+	}
+	if quirks, ok := e.ROM.QuirkyPlatforms[platformID]; ok {
+		quirks.apply(&settings.Quirks)
+	}
+	if e.ROM.Colors != nil {
+		for _, hex := range e.ROM.Colors.Pixels {
+			if c, err := parseColor(hex); err == nil {
+				settings.Palette = append(settings.Palette, c)
+			}
+		}
+	}
+	return settings
+}
+
+// Identify picks the settings to run a ROM with, the same way for every frontend.
+// A ROM in the database gets its entry's settings. Otherwise its platform is
+// guessed from its contents, or else from the extension of its file name, and
+// the platform's defaults are used. It returns false for ROMs that are neither
+// in the database nor recognized, which run with the emulator's defaults.
+// Parameters:
+//   - db: The ROM database, or nil
+//   - name: The ROM's file name, or "" if it has none
+//   - rom: The unpatched ROM
+func Identify(db *Database, name string, rom []byte) (Settings, bool) {
+	if db != nil {
+		if entry, ok := db.Lookup(Hash(rom)); ok {
+			return entry.Settings(), true
+		}
+	}
+	platform, ok := cpu.DetectPlatform(rom)
+	if !ok {
+		platform = extensions[strings.ToLower(filepath.Ext(name))]
+		ok = platform != cpu.PlatformCHIP8
+	}
+	return Settings{Platform: platform, Quirks: platform.Quirks(), Tickrate: platform.Tickrate()}, ok
+}
+
+// apply overrides the quirks that are set in q.
+func (q Quirks) apply(quirks *cpu.Quirks) {
+	set := func(dst *bool, src *bool) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&quirks.Shift, q.Shift)
+	set(&quirks.MemoryIncrementByX, q.MemoryIncrementByX)
+	set(&quirks.MemoryLeaveIUnchanged, q.MemoryLeaveIUnchanged)
+	set(&quirks.Jump, q.Jump)
+	set(&quirks.Logic, q.Logic)
+	set(&quirks.VBlank, q.VBlank)
+	set(&quirks.Wrap, q.Wrap)
+}
+
+// parseColor parses a "#RRGGBB" color.
+func parseColor(hex string) (color.RGBA, error) {
+	if len(hex) != len("#RRGGBB") || hex[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", hex)
+	}
+	rgb, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", hex)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
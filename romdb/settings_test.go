@@ -0,0 +1,183 @@
+package romdb
+
+import (
+	"go-r8t/cpu"
+	"image/color"
+	"testing"
+)
+
+// entry returns the settings of a ROM given as JSON.
+func entry(t *testing.T, rom string) Settings {
+	t.Helper()
+	db, err := Parse([]byte(`[{"title": "Test", "authors": ["Me"], "roms": {"0000000000000000000000000000000000000001": ` + rom + `}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := db.Lookup("0000000000000000000000000000000000000001")
+	if !ok {
+		t.Fatal("test ROM not found")
+	}
+	return e.Settings()
+}
+
+func TestSettingsPlatform(t *testing.T) {
+	tests := []struct {
+		name string
+		rom  string
+		want cpu.Platform
+	}{
+		{"no platform", `{}`, cpu.PlatformCHIP8},
+		{"first supported", `{"platforms": ["megachip8", "superchip", "xochip"]}`, cpu.PlatformSCHIP},
+		{"unsupported", `{"platforms": ["megachip8"]}`, cpu.PlatformCHIP8},
+		{"modern", `{"platforms": ["modernChip8"]}`, cpu.PlatformModernCHIP8},
+		{"ETI-660", `{"platforms": ["originalChip8"], "startAddress": 1536}`, cpu.PlatformETI660},
+		{"ETI-660 without platform", `{"startAddress": 1536}`, cpu.PlatformETI660},
+		{"other start address", `{"platforms": ["originalChip8"], "startAddress": 512}`, cpu.PlatformCHIP8},
+		// Only CHIP-8 ROMs that start at 0x600 are ETI-660 ROMs
+		{"SCHIP at 0x600", `{"platforms": ["superchip"], "startAddress": 1536}`, cpu.PlatformSCHIP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := entry(t, tt.rom)
+			if s.Platform != tt.want {
+				t.Errorf("platform %s, want %s", s.Platform, tt.want)
+			}
+			if s.Quirks != tt.want.Quirks() || s.Tickrate != tt.want.Tickrate() {
+				t.Errorf("quirks %+v at tickrate %d, want %s's defaults", s.Quirks, s.Tickrate, tt.want)
+			}
+			if s.Name != "Test by Me" {
+				t.Errorf("name %q, want %q", s.Name, "Test by Me")
+			}
+		})
+	}
+}
+
+func TestSettingsQuirks(t *testing.T) {
+	// Quirks of the chosen platform override its defaults; the others are ignored
+	s := entry(t, `{
+		"platforms": ["superchip", "xochip"],
+		"quirkyPlatforms": {
+			"superchip": {"shift": false, "jump": false, "vblank": true},
+			"xochip": {"wrap": true}
+		}
+	}`)
+	want := cpu.PlatformSCHIP.Quirks()
+	want.Shift, want.Jump, want.VBlank = false, false, true
+	if s.Quirks != want {
+		t.Errorf("quirks %+v, want %+v", s.Quirks, want)
+	}
+
+	// Overrides are keyed by the database's platform id
+	s = entry(t, `{"platforms": ["originalChip8"], "quirkyPlatforms": {"chip8": {"vblank": false}}}`)
+	if s.Quirks != cpu.PlatformCHIP8.Quirks() {
+		t.Errorf("quirks %+v, want the defaults %+v", s.Quirks, cpu.PlatformCHIP8.Quirks())
+	}
+	s = entry(t, `{"platforms": ["originalChip8"], "quirkyPlatforms": {"originalChip8": {"vblank": false}}}`)
+	if s.Quirks.VBlank {
+		t.Error("the vblank quirk is still set")
+	}
+}
+
+func TestSettingsTickratePaletteKeys(t *testing.T) {
+	s := entry(t, `{
+		"platforms": ["xochip"],
+		"tickrate": 1000,
+		"keys": {"up": 5, "a": 6},
+		"colors": {"pixels": ["#102030", "bad", "#FFEEDD"]}
+	}`)
+	if s.Tickrate != 1000 {
+		t.Errorf("tickrate %d, want 1000", s.Tickrate)
+	}
+	if len(s.Keys) != 2 || s.Keys["up"] != 5 || s.Keys["a"] != 6 {
+		t.Errorf("keys %v, want up 5 and a 6", s.Keys)
+	}
+	// Invalid colors are skipped
+	want := []color.RGBA{{0x10, 0x20, 0x30, 0xFF}, {0xFF, 0xEE, 0xDD, 0xFF}}
+	if len(s.Palette) != len(want) || s.Palette[0] != want[0] || s.Palette[1] != want[1] {
+		t.Errorf("palette %v, want %v", s.Palette, want)
+	}
+
+	s = entry(t, `{"platforms": ["xochip"], "tickrate": 0}`)
+	if s.Tickrate != cpu.PlatformXOCHIP.Tickrate() || s.Palette != nil {
+		t.Errorf("tickrate %d and palette %v, want %d and none", s.Tickrate, s.Palette, cpu.PlatformXOCHIP.Tickrate())
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		hex     string
+		want    color.RGBA
+		wantErr bool
+	}{
+		{hex: "#000000", want: color.RGBA{A: 0xFF}},
+		{hex: "#1a2B3c", want: color.RGBA{0x1A, 0x2B, 0x3C, 0xFF}},
+		{hex: "1a2b3c", wantErr: true},
+		{hex: "#1a2b3", wantErr: true},
+		{hex: "#1a2b3c4d", wantErr: true},
+		{hex: "#1a2b3g", wantErr: true},
+		{hex: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.hex)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseColor(%q) = %v, want an error", tt.hex, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseColor(%q) = %v, %v; want %v", tt.hex, got, err, tt.want)
+		}
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	known := []byte{0x12, 0x00}
+	db, err := Parse([]byte(`[{"title": "Known", "roms": {"` + Hash(known) + `": {"platforms": ["superchip"]}}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hires := []byte{0x12, 0x60, 0x00, 0xE0}
+	unknown := []byte{0x00, 0xE0}
+
+	tests := []struct {
+		name     string
+		db       *Database
+		file     string
+		rom      []byte
+		platform cpu.Platform
+		ok       bool
+	}{
+		{"database", db, "known.ch8", known, cpu.PlatformSCHIP, true},
+		{"database before extension", db, "known.xo8", known, cpu.PlatformSCHIP, true},
+		{"no database", nil, "known.ch8", known, cpu.PlatformCHIP8, false},
+		{"hires", db, "hires.ch8", hires, cpu.PlatformCHIP8Hires, true},
+		{"hires before extension", db, "hires.sc8", hires, cpu.PlatformCHIP8Hires, true},
+		{"SCHIP extension", db, "game.SC8", unknown, cpu.PlatformSCHIP, true},
+		{"XO-CHIP extension", db, "dir.ch8/game.xo8", unknown, cpu.PlatformXOCHIP, true},
+		{"CHIP-8 extension", db, "game.ch8", unknown, cpu.PlatformCHIP8, false},
+		{"no name", db, "", unknown, cpu.PlatformCHIP8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ok := Identify(tt.db, tt.file, tt.rom)
+			if s.Platform != tt.platform || ok != tt.ok {
+				t.Errorf("Identify = %s, %t; want %s, %t", s.Platform, ok, tt.platform, tt.ok)
+			}
+			if s.Quirks != tt.platform.Quirks() || s.Tickrate != tt.platform.Tickrate() {
+				t.Errorf("quirks %+v at tickrate %d, want %s's defaults", s.Quirks, s.Tickrate, tt.platform)
+			}
+		})
+	}
+}
+
+func TestIsROMFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"game.ch8": true, "GAME.SC8": true, "game.xo8": true,
+		"game.c8": false, "game.ch8.ips": false, "ch8": false,
+	} {
+		if got := IsROMFile(name); got != want {
+			t.Errorf("IsROMFile(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
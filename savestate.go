@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"go-r8t/cpu"
+	"go-r8t/userdata"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Keys used by both frontends to save and load the state of the running ROM
+const (
+	saveStateKeyName = "-"
+	loadStateKeyName = "="
+)
+
+// Per-ROM files, named after the ROM's SHA-1
+var (
+	flagsDir   string                    // Directory of the RPL user flags, empty to not persist them
+	statesDir  = userdata.Path("states") // Directory of the save states
+	savedFlags [16]byte                  // Flags as last read from or written to disk
+)
+
+// loadFlags restores the RPL user flags the ROM saved in an earlier session
+func loadFlags(chip8 *cpu.CPU) error {
+	savedFlags = [16]byte{}
+	if flagsDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(flagsDir, currentROMHash+".flags"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	copy(chip8.Flags[:], data)
+	savedFlags = chip8.Flags
+	return nil
+}
+
+// persistFlags writes the RPL user flags to disk when the ROM changed them.
+// It is called after every frame, so high scores survive a crash or a closed window.
+func persistFlags(chip8 *cpu.CPU) {
+	if flagsDir == "" || chip8.Flags == savedFlags {
+		return
+	}
+	savedFlags = chip8.Flags
+	path := filepath.Join(flagsDir, currentROMHash+".flags")
+	if err := userdata.WriteFile(path, chip8.Flags[:chip8.Platform.FlagCount()]); err != nil {
+		SetStatus("Failed to save flags: " + err.Error())
+	}
+}
+
+// saveState saves the state of the running ROM to its save state file
+func saveState(chip8 *cpu.CPU) {
+	data, err := chip8.SaveState()
+	if err == nil {
+		err = userdata.WriteFile(filepath.Join(statesDir, currentROMHash+".state"), data)
+	}
+	if err != nil {
+		SetStatus("Failed to save state: " + err.Error())
+		return
+	}
+	SetStatus("State saved")
+}
+
+// loadState restores the running ROM's state from its save state file
+func loadState(chip8 *cpu.CPU) {
+	data, err := os.ReadFile(filepath.Join(statesDir, currentROMHash+".state"))
+	if errors.Is(err, fs.ErrNotExist) {
+		SetStatus("No saved state for this ROM")
+		return
+	}
+	if err == nil {
+		err = chip8.LoadState(data)
+	}
+	if err != nil {
+		SetStatus("Failed to load state: " + err.Error())
+		return
+	}
+	SetStatus("State loaded")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"go-r8t/cpu"
+	"io"
+	"os"
+	"time"
+)
+
+// Frame rate of the terminal frontend, matching the 60Hz timers
+const terminalFrameRate = 60
+
+// terminalOut is where escape sequences that termbox doesn't know about are written.
+// termbox renders to /dev/tty, so they are sent to the same device.
+var terminalOut io.Writer
+
+// writeTerminal writes a raw escape sequence to the terminal
+func writeTerminal(sequence string) {
+	if terminalOut == nil {
+		terminalOut = os.Stdout
+		if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+			terminalOut = tty
+		}
+	}
+	io.WriteString(terminalOut, sequence)
+}
+
+// RunTerminal runs the emulator in the terminal until ESC is pressed.
+// With a launcher, ESC returns to the ROM list instead, and quits from there.
+// Parameters:
+//   - chip8: The CPU to run, or nil to start in the launcher
+//   - launcher: The ROM library launcher, or nil
+func RunTerminal(chip8 *cpu.CPU, launcher *Launcher) error {
+	if err := InitializeTerminal(); err != nil {
+		return err
+	}
+	defer CloseTerminal()
+
+	// The input goroutine is stopped before the terminal is closed
+	quit := make(chan struct{})
+	defer StopKeyboardInput()
+	defer close(quit)
+
+	keyboard := NewKeyboard()
+	StartKeyboardInput(keyboard, quit)
+
+	ticker := time.NewTicker(time.Second / terminalFrameRate)
+	defer ticker.Stop()
+
+	inLauncher := chip8 == nil
+	for now := range ticker.C {
+		if inLauncher {
+			for _, name := range keyboard.Presses() {
+				boot, exit := launcher.HandleKey(name)
+				if exit {
+					return nil
+				}
+				if boot == "" {
+					continue
+				}
+				booted, err := bootROM(boot)
+				if err != nil {
+					SetStatus(err.Error())
+					continue
+				}
+				chip8, inLauncher = booted, false
+				displayBuffer = nil
+				break
+			}
+			if inLauncher {
+				LauncherDisplay(launcher)
+				continue
+			}
+		}
+
+		// Key state only changes between frames, never while the CPU runs
+		if !keyboard.Update(chip8, now) {
+			err := endSession(chip8)
+			if launcher == nil {
+				return err
+			}
+			inLauncher = true
+			launcher.Refresh()
+			SetStatus("")
+			if err != nil {
+				SetStatus(err.Error())
+			}
+			continue
+		}
+		if !keyboard.Paused() {
+			runFrame(chip8)
+		}
+		TerminalDisplay(chip8.Snapshot(), chip8.TakeDirty())
+	}
+	return nil
+}
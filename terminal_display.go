@@ -1,67 +1,346 @@
 package main
 
 import (
+	"fmt"
 	"go-r8t/cpu"
+	"strings"
 
 	"github.com/nsf/termbox-go"
 )
 
 // Display buffer with fade-out state to reduce flickering
-var displayBuffer [64 * 32]int
+var displayBuffer []int
 
-// TerminalDisplay is responsible for rendering the CHIP-8 display in the terminal
-func TerminalDisplay(cpu *cpu.CPU) {
-	// Clear screen
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+// Number of pixels in displayBuffer that are fading out
+var fadingPixels int
+
+// terminalLayout is everything besides the display that a rendered frame depends on
+type terminalLayout struct {
+	termWidth, termHeight int
+	mode                  RenderMode
+	width, height         int
+	rom, status           string
+}
+
+// Layout of the last rendered frame
+var renderedLayout terminalLayout
+
+// RenderMode selects how CHIP-8 pixels are mapped onto the terminal.
+type RenderMode int
+
+const (
+	RenderAuto      RenderMode = iota // Pick the best mode for the terminal size and capabilities
+	RenderBlock                       // Two full block cells per pixel
+	RenderHalfBlock                   // Upper half block with fg/bg colors, 1x2 pixels per cell
+	RenderBraille                     // Braille patterns, 2x4 pixels per cell
+	RenderSixel                       // DEC sixel graphics
+	RenderKitty                       // Kitty graphics protocol
+)
+
+var renderModeNames = map[RenderMode]string{
+	RenderAuto:      "auto",
+	RenderBlock:     "block",
+	RenderHalfBlock: "half",
+	RenderBraille:   "braille",
+	RenderSixel:     "sixel",
+	RenderKitty:     "kitty",
+}
+
+// String returns the name of the render mode as accepted by ParseRenderMode.
+func (m RenderMode) String() string {
+	return renderModeNames[m]
+}
+
+// ParseRenderMode converts a render mode name into a RenderMode.
+func ParseRenderMode(name string) (RenderMode, error) {
+	for mode, modeName := range renderModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return RenderAuto, fmt.Errorf("unknown render mode %q", name)
+}
+
+// Requested render mode
+var renderMode = RenderAuto
+
+// SetRenderMode sets the render mode used by TerminalDisplay
+func SetRenderMode(mode RenderMode) {
+	renderMode = mode
+}
+
+// displayCells returns the number of terminal cells a width x height display
+// occupies in the given text render mode.
+func displayCells(mode RenderMode, width, height int) (cols, rows int) {
+	switch mode {
+	case RenderHalfBlock:
+		return width, (height + 1) / 2
+	case RenderBraille:
+		return (width + 1) / 2, (height + 3) / 4
+	default:
+		return width * 2, height
+	}
+}
 
-	// Build the display string
-	display := cpu.GetDisplay()
+// selectRenderMode resolves RenderAuto into a concrete render mode.
+// Graphics protocols are preferred when available. Otherwise the lowest density
+// text mode that fits the terminal is used, since it has the squarest pixels.
+func selectRenderMode(mode RenderMode, termWidth, termHeight, width, height int, caps terminalCapabilities) RenderMode {
+	if mode != RenderAuto {
+		return mode
+	}
+	if caps.Kitty {
+		return RenderKitty
+	}
+	if caps.Sixel {
+		return RenderSixel
+	}
+	for _, candidate := range []RenderMode{RenderBlock, RenderHalfBlock} {
+		cols, rows := displayCells(candidate, width, height)
+		// Leave room for the border and the ROM information and status lines
+		if cols+2 <= termWidth && rows+4 <= termHeight {
+			return candidate
+		}
+	}
+	return RenderBraille
+}
 
-	// Calculate display dimensions
-	termWidth, _ := termbox.Size()
-	startX := (termWidth - 128) / 2
+// TerminalDisplay is responsible for rendering the CHIP-8 display in the terminal.
+// It draws a snapshot taken between frames, so it never sees a half-executed frame.
+// Only the cells covering the changed regions (dirty) and the pixels that are
+// fading out are redrawn, and nothing is drawn when nothing changed. The whole
+// screen is redrawn when anything around the display changes.
+func TerminalDisplay(display cpu.DisplaySnapshot, dirty []cpu.Rect) {
+	width, height := display.Width, display.Height
+	termWidth, termHeight := termbox.Size()
+
+	layout := terminalLayout{termWidth, termHeight, renderMode, width, height, currentROM, statusMessage}
+	full := len(displayBuffer) != len(display.Pixels) || layout != renderedLayout
+	if !full && len(dirty) == 0 && fadingPixels == 0 {
+		return
+	}
+	renderedLayout = layout
+
+	// Update buffer with new pixel states, starting over when the display mode changes
+	if len(displayBuffer) != len(display.Pixels) {
+		displayBuffer = make([]int, len(display.Pixels))
+		changedPixels = make([]bool, len(display.Pixels))
+	}
+	if full {
+		dirty = []cpu.Rect{{X: 0, Y: 0, Width: width, Height: height}}
+	}
+	updateDisplayBuffer(display, dirty)
+
+	// Pick the render mode and calculate display dimensions
+	mode := selectRenderMode(renderMode, termWidth, termHeight, width, height, detectCapabilities())
+
+	var cols, rows int
+	switch mode {
+	case RenderSixel, RenderKitty:
+		// Fit the image into the terminal, keeping the 2:1 aspect ratio
+		cols = min(termWidth-2, width*2)
+		rows = cols * cellPixelWidth * height / width / cellPixelHeight
+	default:
+		cols, rows = displayCells(mode, width, height)
+	}
+
+	startX := (termWidth - cols - 2) / 2
 	if startX < 0 {
 		startX = 0
 	}
 
-	// Render border and display
-	renderBorder(startX, 0, 128+2, 32+2)
+	changed := changedPixels
+	if full {
+		// Render border, display and current ROM information
+		changed = nil
+		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+		renderBorder(startX, 0, cols+2, rows+2)
+		renderROMInfo(startX, rows+2)
+	}
+	switch mode {
+	case RenderSixel, RenderKitty:
+		// Images are drawn over the cells after termbox has flushed them
+	default:
+		renderCells(mode, startX+1, 1, width, height, changed)
+	}
 
-	// Render the CHIP-8 display with phosphor effect
-	for y := 0; y < 32; y++ {
-		for x := 0; x < 64; x++ {
-			index := y*64 + x
+	// Force update
+	termbox.Flush()
 
-			// Update buffer with new pixel state
-			if display[index] == 1 {
-				displayBuffer[index] = 3 // Full brightness
-			} else if displayBuffer[index] > 0 {
-				displayBuffer[index]-- // Fade out
-			}
+	switch mode {
+	case RenderSixel:
+		scale := max(1, cols*cellPixelWidth/width)
+		writeGraphics(startX+1, 1, encodeSixel(displayBuffer, changed, width, height, scale))
+	case RenderKitty:
+		// The terminal scales kitty images to the cells, so parts of the image
+		// can't be placed exactly; the whole image is replaced instead
+		writeGraphics(startX+1, 1, encodeKitty(displayBuffer, width, height, cols, rows))
+	}
+}
+
+// Pixels of displayBuffer whose brightness changed in the last frame
+var changedPixels []bool
 
-			// Set character and color based on buffer state
+// updateDisplayBuffer lights up the pixels that are lit in the dirty regions
+// of the display, fades out the others and records which pixels changed.
+func updateDisplayBuffer(display cpu.DisplaySnapshot, dirty []cpu.Rect) {
+	clear(changedPixels)
+	fadingPixels = 0
+	for index, level := range displayBuffer {
+		if level > 0 && display.Pixels[index] == 0 {
+			displayBuffer[index]-- // Fade out
+			changedPixels[index] = true
 			if displayBuffer[index] > 0 {
-				var color termbox.Attribute
-				switch displayBuffer[index] {
-				case 3:
-					color = termbox.ColorWhite // Full brightness
-				case 2:
-					color = termbox.ColorWhite // Medium brightness
-				case 1:
-					color = termbox.ColorDarkGray // Dim
+				fadingPixels++
+			}
+		}
+	}
+	for _, r := range dirty {
+		for py := max(r.Y, 0); py < min(r.Y+r.Height, display.Height); py++ {
+			for px := max(r.X, 0); px < min(r.X+r.Width, display.Width); px++ {
+				index := py*display.Width + px
+				if display.Pixels[index] == 1 && displayBuffer[index] != 3 {
+					displayBuffer[index] = 3 // Full brightness
+					changedPixels[index] = true
 				}
+			}
+		}
+	}
+}
 
-				// Set two characters for each pixel (for better aspect ratio)
-				termbox.SetCell(startX+1+x*2, y+1, '█', color, termbox.ColorDefault)
-				termbox.SetCell(startX+1+x*2+1, y+1, '█', color, termbox.ColorDefault)
+// fadeColor returns the terminal color for a display buffer brightness level
+func fadeColor(level int) termbox.Attribute {
+	switch level {
+	case 3:
+		return termbox.ColorWhite // Full brightness
+	case 2:
+		return termbox.ColorWhite // Medium brightness
+	case 1:
+		return termbox.ColorDarkGray // Dim
+	}
+	return termbox.ColorDefault
+}
+
+// renderCells draws the display in a text render mode at cell (x, y).
+// With changed set, only the cells that show a changed pixel are drawn;
+// otherwise all of them are.
+func renderCells(mode RenderMode, x, y, width, height int, changed []bool) {
+	if changed == nil {
+		stepX, stepY := pixelsPerCell(mode)
+		for py := 0; py < height; py += stepY {
+			for px := 0; px < width; px += stepX {
+				renderCell(mode, x, y, px, py, width, height)
 			}
 		}
+		return
+	}
+	for index, pixelChanged := range changed {
+		if pixelChanged {
+			renderCell(mode, x, y, index%width, index/width, width, height)
+		}
+	}
+}
+
+// pixelsPerCell returns the number of pixels a cell of a text render mode shows
+// horizontally and vertically.
+func pixelsPerCell(mode RenderMode) (x, y int) {
+	switch mode {
+	case RenderHalfBlock:
+		return 1, 2
+	case RenderBraille:
+		return 2, 4
+	default:
+		return 1, 1
 	}
+}
 
-	// Render current ROM information
-	renderROMInfo(startX, 34)
+// renderCell draws the cell that shows the pixel (px, py), including empty cells.
+func renderCell(mode RenderMode, x, y, px, py, width, height int) {
+	stepX, stepY := pixelsPerCell(mode)
+	px, py = px-px%stepX, py-py%stepY
+	switch mode {
+	case RenderHalfBlock:
+		renderHalfBlock(x+px, y+py/2, px, py, width, height)
+	case RenderBraille:
+		renderBrailleCell(x+px/2, y+py/4, px, py, width, height)
+	default:
+		renderBlock(x+px*2, y+py, displayBuffer[py*width+px])
+	}
+}
+
+// renderBlock draws a pixel as two full block characters at cell (x, y)
+func renderBlock(x, y, level int) {
+	// Set two characters for each pixel (for better aspect ratio)
+	ch := ' '
+	if level > 0 {
+		ch = '█'
+	}
+	color := fadeColor(level)
+	termbox.SetCell(x, y, ch, color, termbox.ColorDefault)
+	termbox.SetCell(x+1, y, ch, color, termbox.ColorDefault)
+}
+
+// renderHalfBlock draws the two vertically stacked pixels starting at (px, py)
+// at cell (x, y). The upper pixel uses the foreground color of '▀' and the
+// lower one the background.
+func renderHalfBlock(x, y, px, py, width, height int) {
+	top := displayBuffer[py*width+px]
+	bottom := 0
+	if py+1 < height {
+		bottom = displayBuffer[(py+1)*width+px]
+	}
+	if top == 0 && bottom == 0 {
+		termbox.SetCell(x, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		return
+	}
+	termbox.SetCell(x, y, '▀', fadeColor(top), fadeColor(bottom))
+}
+
+// Braille dot bits indexed by [row][column] within a 2x4 cell
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// renderBrailleCell draws the 2x4 block of pixels starting at (px, py) as a
+// braille pattern at cell (x, y). A cell only has one color, so it takes the
+// brightest pixel's color.
+func renderBrailleCell(x, y, px, py, width, height int) {
+	var pattern rune
+	brightest := 0
+	for dy := 0; dy < 4 && py+dy < height; dy++ {
+		for dx := 0; dx < 2 && px+dx < width; dx++ {
+			level := displayBuffer[(py+dy)*width+px+dx]
+			if level > 0 {
+				pattern |= brailleDots[dy][dx]
+				brightest = max(brightest, level)
+			}
+		}
+	}
+	if pattern == 0 {
+		termbox.SetCell(x, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		return
+	}
+	termbox.SetCell(x, y, 0x2800+pattern, fadeColor(brightest), termbox.ColorDefault)
+}
+
+// LauncherDisplay renders the ROM library launcher in the terminal
+func LauncherDisplay(launcher *Launcher) {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+
+	termWidth, termHeight := termbox.Size()
+	lines := launcher.Lines(termHeight-1, termWidth)
+	for y, line := range lines {
+		color := termbox.ColorWhite
+		if strings.HasPrefix(line, "> ") {
+			color = termbox.ColorYellow
+		}
+		drawString(0, y, line, color)
+	}
+	drawString(0, termHeight-1, statusMessage, termbox.ColorYellow)
 
-	// Force update
 	termbox.Flush()
 }
 
@@ -96,8 +375,11 @@ func renderBorder(x, y, width, height int) {
 	termbox.SetCell(x+width-1, y+height-1, '┘', termbox.ColorWhite, termbox.ColorDefault)
 }
 
+// ROM name shown before a ROM is loaded
+const noROM = "No ROM loaded"
+
 // Current ROM name
-var currentROM = "No ROM loaded"
+var currentROM = noROM
 
 // SetCurrentROM sets the current ROM name
 func SetCurrentROM(name string) {
@@ -106,7 +388,8 @@ func SetCurrentROM(name string) {
 
 // renderROMInfo renders information about the current ROM
 func renderROMInfo(x, y int) {
-	drawString(x, y, "ROM: "+currentROM+" (Press ESC to exit)", termbox.ColorWhite)
+	drawString(x, y, "ROM: "+currentROM+" (ESC: leave, TAB: bind keys, `: cheats, -/=: save/load state)", termbox.ColorWhite)
+	drawString(x, y+1, statusMessage, termbox.ColorYellow)
 }
 
 // drawString draws a string at the specified position
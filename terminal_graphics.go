@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Graphics protocol image dimensions are estimated with this cell size in pixels,
+// since termbox has no way to query the real font metrics.
+const (
+	cellPixelWidth  = 8
+	cellPixelHeight = 16
+)
+
+// Kitty graphics protocol payloads must be sent in chunks of at most 4096 bytes.
+const kittyChunkSize = 4096
+
+// terminalCapabilities describes the graphics protocols the terminal supports.
+type terminalCapabilities struct {
+	Kitty bool // Kitty graphics protocol
+	Sixel bool // DEC sixel graphics
+}
+
+// detectCapabilities guesses the terminal's graphics support from its environment.
+// Querying the terminal directly would race with termbox's input handling.
+func detectCapabilities() terminalCapabilities {
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+
+	var caps terminalCapabilities
+	caps.Kitty = term == "xterm-kitty" || os.Getenv("KITTY_WINDOW_ID") != "" ||
+		program == "WezTerm" || program == "ghostty"
+	caps.Sixel = strings.Contains(term, "sixel") || strings.HasPrefix(term, "mlterm") ||
+		strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "yaft") ||
+		program == "WezTerm" || program == "iTerm.app"
+	return caps
+}
+
+// writeGraphics writes an escape sequence that draws an image at cell (x, y).
+// Parameters:
+//   - x, y: The top-left terminal cell of the image (0-based)
+//   - sequence: The image escape sequence
+func writeGraphics(x, y int, sequence []byte) {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b7") // Save cursor
+	fmt.Fprintf(&buf, "\x1b[%d;%dH", y+1, x+1)
+	buf.Write(sequence)
+	buf.WriteString("\x1b8") // Restore cursor
+	writeTerminal(buf.String())
+}
+
+// encodeSixel encodes a brightness map as a sixel image.
+// Each source pixel is drawn as a scale x scale block. Pixels that aren't drawn
+// keep what is on the screen, so only the changed pixels are drawn when changed
+// is set; a nil changed draws the whole image.
+// Parameters:
+//   - levels: Brightness of each pixel (0 = off, 3 = full brightness)
+//   - changed: The pixels to draw, or nil for all of them
+//   - width, height: The size of the brightness map in pixels
+//   - scale: The number of image pixels per source pixel
+func encodeSixel(levels []int, changed []bool, width, height, scale int) []byte {
+	var buf bytes.Buffer
+	imgWidth, imgHeight := width*scale, height*scale
+
+	// Enter sixel mode with a 1:1 pixel aspect ratio, leaving pixels that
+	// aren't drawn transparent, and define the palette
+	fmt.Fprintf(&buf, "\x1bP0;1;0q\"1;1;%d;%d", imgWidth, imgHeight)
+	buf.WriteString("#0;2;0;0;0#1;2;40;40;40#2;2;75;75;75#3;2;100;100;100")
+
+	// Unlit pixels are drawn in color 0: termbox doesn't repaint the cells
+	// under the image, so pixels that fade out would otherwise keep their last color
+	row := make([]byte, imgWidth)
+	for band := 0; band < imgHeight; band += 6 {
+		for colorIndex := 0; colorIndex <= 3; colorIndex++ {
+			used := false
+			for px := 0; px < imgWidth; px++ {
+				var bits byte
+				for bit := 0; bit < 6 && band+bit < imgHeight; bit++ {
+					idx := ((band+bit)/scale)*width + px/scale
+					if levels[idx] == colorIndex && (changed == nil || changed[idx]) {
+						bits |= 1 << bit
+					}
+				}
+				row[px] = 0x3F + bits
+				used = used || bits != 0
+			}
+			if !used {
+				continue
+			}
+			fmt.Fprintf(&buf, "#%d", colorIndex)
+			writeSixelRow(&buf, row)
+			buf.WriteByte('$') // Carriage return within the band
+		}
+		buf.WriteByte('-') // Next band
+	}
+
+	buf.WriteString("\x1b\\")
+	return buf.Bytes()
+}
+
+// writeSixelRow writes one band of sixel characters using run-length encoding.
+func writeSixelRow(buf *bytes.Buffer, row []byte) {
+	for i := 0; i < len(row); {
+		run := 1
+		for i+run < len(row) && row[i+run] == row[i] {
+			run++
+		}
+		if run > 3 {
+			fmt.Fprintf(buf, "!%d%c", run, row[i])
+		} else {
+			for j := 0; j < run; j++ {
+				buf.WriteByte(row[i])
+			}
+		}
+		i += run
+	}
+}
+
+// encodeKitty encodes a brightness map as a kitty graphics protocol image.
+// The terminal scales the image to fill cols x rows cells.
+// Parameters:
+//   - levels: Brightness of each pixel (0 = off, 3 = full brightness)
+//   - width, height: The size of the brightness map in pixels
+//   - cols, rows: The number of terminal cells the image should cover
+func encodeKitty(levels []int, width, height, cols, rows int) []byte {
+	rgb := make([]byte, 0, width*height*3)
+	for _, level := range levels[:width*height] {
+		v := byte(level * 255 / 3)
+		rgb = append(rgb, v, v, v)
+	}
+	payload := base64.StdEncoding.EncodeToString(rgb)
+
+	var buf bytes.Buffer
+	for offset := 0; offset < len(payload); offset += kittyChunkSize {
+		end := offset + kittyChunkSize
+		more := 1
+		if end >= len(payload) {
+			end = len(payload)
+			more = 0
+		}
+		if offset == 0 {
+			// Image id 1 is replaced on every frame instead of stacking new images
+			fmt.Fprintf(&buf, "\x1b_Ga=T,f=24,i=1,q=2,C=1,s=%d,v=%d,c=%d,r=%d,m=%d;",
+				width, height, cols, rows, more)
+		} else {
+			fmt.Fprintf(&buf, "\x1b_Gm=%d;", more)
+		}
+		buf.WriteString(payload[offset:end])
+		buf.WriteString("\x1b\\")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,25 @@
+// Package userdata locates the files the emulator keeps in the user's configuration directory.
+package userdata
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Path returns the path of a file or directory in the emulator's user configuration
+// directory, or in the current directory if the system has none.
+func Path(name string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "go-r8t", name)
+}
+
+// WriteFile writes a file, creating its directory if needed.
+func WriteFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}